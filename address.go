@@ -0,0 +1,336 @@
+package coinpayments
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//ErrAddressUnsupported is returned by ValidateAddress for currencies it has no offline rules for
+var ErrAddressUnsupported = errors.New("coinpayments: no address rules for currency")
+
+const (
+	bitcoinAlphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	rippleAlphabet  = "rpshnaf39wBUDNEGHJKLM4PQRST7VWXYZ2bcdeCg65jkm8oFqi1tuvAxyz"
+	bech32Charset   = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+//addressRule describes the address formats accepted for a currency
+type addressRule struct {
+	versions     []byte
+	alphabet     string
+	segwitHRP    string
+	cashAddrHRP  string
+	hex          bool
+	destTagInt32 bool
+}
+
+//addressRules maps currency codes to the formats ValidateAddress checks
+var addressRules = map[string]addressRule{
+	"BTC":  {versions: []byte{0x00, 0x05}, alphabet: bitcoinAlphabet, segwitHRP: "bc"},
+	"LTC":  {versions: []byte{0x30, 0x32, 0x05}, alphabet: bitcoinAlphabet, segwitHRP: "ltc"},
+	"LTCT": {versions: []byte{0x6f, 0x3a, 0xc4}, alphabet: bitcoinAlphabet, segwitHRP: "tltc"},
+	"BCH":  {versions: []byte{0x00, 0x05}, alphabet: bitcoinAlphabet, cashAddrHRP: "bitcoincash"},
+	"DOGE": {versions: []byte{0x1e, 0x16}, alphabet: bitcoinAlphabet},
+	"DASH": {versions: []byte{0x4c, 0x10}, alphabet: bitcoinAlphabet},
+	"XRP":  {versions: []byte{0x00}, alphabet: rippleAlphabet, destTagInt32: true},
+	"ETH":  {hex: true},
+	"ETC":  {hex: true},
+}
+
+//lookupAddressRule finds the rule for a currency, treating ERC20 and BEP20 tokens as ethereum addresses
+func lookupAddressRule(currency string) (addressRule, bool) {
+	currency = strings.ToUpper(currency)
+	if rule, ok := addressRules[currency]; ok {
+		return rule, true
+	}
+	if strings.HasSuffix(currency, ".ERC20") || strings.HasSuffix(currency, ".BEP20") {
+		return addressRule{hex: true}, true
+	}
+	return addressRule{}, false
+}
+
+//WithAddressValidation is an option that makes the Client validate destination addresses before withdrawing
+func WithAddressValidation() ClientOption {
+	return func(client *Client) {
+		client.validateAddresses = true
+	}
+}
+
+//ValidateAddress checks the format and checksum of an address, and its destination tag if one is given
+func ValidateAddress(currency, address, destTag string) error {
+	err := checkAddress(currency, address, destTag)
+	if err == nil || err == ErrAddressUnsupported {
+		return err
+	}
+	return fmt.Errorf("coinpayments: %v", err)
+}
+
+//validateAddressValues applies ValidateAddress to the destination in a withdrawal request
+func validateAddressValues(currency, address, destTag string) error {
+	if address == "" {
+		return nil
+	}
+
+	err := checkAddress(currency, address, destTag)
+	if err == nil || err == ErrAddressUnsupported {
+		return nil
+	}
+	return ValidationErrors{{Field: "address", Reason: err.Error()}}
+}
+
+//checkAddress is ValidateAddress returning the reason an address is invalid without the package prefix
+func checkAddress(currency, address, destTag string) error {
+	rule, ok := lookupAddressRule(currency)
+	if !ok {
+		return ErrAddressUnsupported
+	}
+
+	if destTag != "" && rule.destTagInt32 {
+		if _, err := strconv.ParseUint(destTag, 10, 32); err != nil {
+			return fmt.Errorf("destination tag %q is not an unsigned 32-bit integer", destTag)
+		}
+	}
+
+	if rule.hex {
+		return validateEIP55(address)
+	}
+
+	if rule.segwitHRP != "" && strings.HasPrefix(strings.ToLower(address), rule.segwitHRP+"1") {
+		return validateSegwit(rule.segwitHRP, address)
+	}
+
+	if rule.cashAddrHRP != "" {
+		lower := strings.ToLower(address)
+		if strings.HasPrefix(lower, rule.cashAddrHRP+":") || strings.HasPrefix(lower, "q") || strings.HasPrefix(lower, "p") {
+			return validateCashAddr(rule.cashAddrHRP, address)
+		}
+	}
+
+	return validateBase58Check(rule.alphabet, rule.versions, address)
+}
+
+func base58Decode(alphabet, s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := strings.IndexRune(alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	decoded := n.Bytes()
+	for _, r := range s {
+		if r != rune(alphabet[0]) {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+	return decoded, nil
+}
+
+func validateBase58Check(alphabet string, versions []byte, address string) error {
+	decoded, err := base58Decode(alphabet, address)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != 25 {
+		return fmt.Errorf("address has invalid length")
+	}
+
+	payload, checksum := decoded[:21], decoded[21:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(checksum, second[:4]) {
+		return fmt.Errorf("address checksum mismatch")
+	}
+
+	for _, v := range versions {
+		if payload[0] == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("address version byte 0x%02x is not valid for this currency", payload[0])
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+//decodeBase32 maps bech32 charset characters to their 5-bit values
+func decodeBase32(s string) ([]byte, error) {
+	data := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		p := strings.IndexByte(bech32Charset, s[i])
+		if p < 0 {
+			return nil, fmt.Errorf("invalid bech32 character %q", s[i])
+		}
+		data[i] = byte(p)
+	}
+	return data, nil
+}
+
+//convertBits regroups a slice of from-bit values into to-bit values without padding
+func convertBits(data []byte, from, to uint) ([]byte, error) {
+	var acc, n uint
+	var out []byte
+	for _, v := range data {
+		acc = acc<<from | uint(v)
+		n += from
+		for n >= to {
+			n -= to
+			out = append(out, byte(acc>>n&(1<<to-1)))
+		}
+	}
+	if n >= from || acc&(1<<n-1) != 0 {
+		return nil, fmt.Errorf("invalid padding in address data")
+	}
+	return out, nil
+}
+
+func validateSegwit(hrp, address string) error {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return fmt.Errorf("address mixes upper and lower case")
+	}
+	address = strings.ToLower(address)
+
+	sep := strings.LastIndexByte(address, '1')
+	if address[:sep] != hrp || len(address)-sep-1 < 7 || len(address) > 90 {
+		return fmt.Errorf("malformed segwit address")
+	}
+
+	data, err := decodeBase32(address[sep+1:])
+	if err != nil {
+		return err
+	}
+
+	expanded := make([]byte, 0, len(hrp)*2+1+len(data))
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&0x1f)
+	}
+	expanded = append(expanded, data...)
+
+	version := data[0]
+	want := uint32(bech32Const)
+	if version > 0 {
+		want = bech32mConst
+	}
+	if bech32Polymod(expanded) != want {
+		return fmt.Errorf("address checksum mismatch")
+	}
+
+	program, err := convertBits(data[1:len(data)-6], 5, 8)
+	if err != nil {
+		return err
+	}
+	if version > 16 || len(program) < 2 || len(program) > 40 || (version == 0 && len(program) != 20 && len(program) != 32) {
+		return fmt.Errorf("invalid witness program")
+	}
+	return nil
+}
+
+func cashAddrPolymod(values []byte) uint64 {
+	generator := [5]uint64{0x98f2bc8e61, 0x79b76d99e2, 0xf33e5fb3c4, 0xae2eabe2a8, 0x1e4f43e470}
+	c := uint64(1)
+	for _, v := range values {
+		top := c >> 35
+		c = (c&0x07ffffffff)<<5 ^ uint64(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				c ^= generator[i]
+			}
+		}
+	}
+	return c ^ 1
+}
+
+func validateCashAddr(hrp, address string) error {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return fmt.Errorf("address mixes upper and lower case")
+	}
+	address = strings.ToLower(address)
+	address = strings.TrimPrefix(address, hrp+":")
+
+	data, err := decodeBase32(address)
+	if err != nil {
+		return err
+	}
+
+	expanded := make([]byte, 0, len(hrp)+1+len(data))
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&0x1f)
+	}
+	expanded = append(expanded, 0)
+	expanded = append(expanded, data...)
+
+	if len(data) < 8 || cashAddrPolymod(expanded) != 0 {
+		return fmt.Errorf("address checksum mismatch")
+	}
+
+	payload, err := convertBits(data[:len(data)-8], 5, 8)
+	if err != nil {
+		return err
+	}
+	if len(payload) != 21 || (payload[0] != 0x00 && payload[0] != 0x08) {
+		return fmt.Errorf("invalid cashaddr payload")
+	}
+	return nil
+}
+
+func validateEIP55(address string) error {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return fmt.Errorf("address must be 0x followed by 40 hex characters")
+	}
+
+	body := address[2:]
+	if _, err := hex.DecodeString(body); err != nil {
+		return fmt.Errorf("address must be 0x followed by 40 hex characters")
+	}
+
+	if strings.ToLower(body) == body || strings.ToUpper(body) == body {
+		return nil
+	}
+
+	hash := keccak256([]byte(strings.ToLower(body)))
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c >= '0' && c <= '9' {
+			continue
+		}
+
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if (c >= 'A' && c <= 'F') != (nibble >= 8) {
+			return fmt.Errorf("address EIP-55 checksum mismatch")
+		}
+	}
+	return nil
+}
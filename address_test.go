@@ -0,0 +1,106 @@
+package coinpayments
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		address  string
+		destTag  string
+		valid    bool
+	}{
+		{"btc p2pkh", "BTC", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "", true},
+		{"btc p2sh", "btc", "3CNHUhP3uyB9EUtRLsmvFUmvGdjGdkTxJw", "", true},
+		{"btc bad checksum", "BTC", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMJ", "", false},
+		{"btc bad character", "BTC", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAM0", "", false},
+		{"btc wrong version", "BTC", "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", "", false},
+		{"btc segwit v0", "BTC", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "", true},
+		{"btc segwit v0 upper case", "BTC", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "", true},
+		{"btc segwit v0 script", "BTC", "bc1q94c3vs4hy6cygqtz0j5lhtpj7hy9xra3jq7vfkczykr30ys6fzqsmphc8w", "", true},
+		{"btc taproot", "BTC", "bc1p94c3vs4hy6cygqtz0j5lhtpj7hy9xra3jq7vfkczykr30ys6fzqs3kh3lj", "", true},
+		{"btc segwit mixed case", "BTC", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kV8F3T4", "", false},
+		{"btc segwit bad checksum", "BTC", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", "", false},
+		{"btc segwit v1 with bech32 checksum", "BTC", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "", false},
+		{"btc segwit wrong hrp", "BTC", "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", "", false},
+		{"ltc", "LTC", "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", "", true},
+		{"ltc segwit", "LTC", "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", "", true},
+		{"ltc bitcoin address", "LTC", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "", false},
+		{"ltct", "LTCT", "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r", "", true},
+		{"ltct segwit", "LTCT", "tltc1qw508d6qejxtdg4y5r3zarvary0c5xw7klfsuq0", "", true},
+		{"bch legacy", "BCH", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "", true},
+		{"bch cashaddr", "BCH", "bitcoincash:qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h", "", true},
+		{"bch cashaddr without prefix", "BCH", "qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h", "", true},
+		{"bch cashaddr p2sh", "BCH", "bitcoincash:pp63uahgrxged4z5jswyt5dn5v3lzsem6cnsdw2m32", "", true},
+		{"bch cashaddr bad checksum", "BCH", "bitcoincash:qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2j", "", false},
+		{"doge", "DOGE", "DFpN6QqFfUm3gKNaxN6tNcab1FArL9cZLE", "", true},
+		{"doge bitcoin address", "DOGE", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "", false},
+		{"dash", "DASH", "XmN7PQYWKn5MJFna5fRYgP6mxT2F7xpekE", "", true},
+		{"xrp", "XRP", "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh", "", true},
+		{"xrp with dest tag", "XRP", "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh", "4294967295", true},
+		{"xrp dest tag too large", "XRP", "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh", "4294967296", false},
+		{"xrp dest tag not a number", "XRP", "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh", "memo", false},
+		{"xrp bitcoin alphabet", "XRP", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "", false},
+		{"eth checksummed", "ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "", true},
+		{"eth checksummed 2", "ETH", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "", true},
+		{"eth checksummed 3", "ETH", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", "", true},
+		{"eth checksummed 4", "ETH", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", "", true},
+		{"eth lower case", "ETH", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "", true},
+		{"eth bad checksum", "ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "", false},
+		{"eth too short", "ETH", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", "", false},
+		{"eth no prefix", "ETH", "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed00", "", false},
+		{"erc20 token", "USDT.ERC20", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "", true},
+		{"bep20 token bad checksum", "USDT.BEP20", "0xFb6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateAddress(tt.currency, tt.address, tt.destTag)
+		if tt.valid && err != nil {
+			t.Errorf("%v: ValidateAddress returned %v, want nil", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%v: ValidateAddress returned nil, want an error", tt.name)
+		}
+	}
+}
+
+func TestValidateAddressUnsupported(t *testing.T) {
+	if err := ValidateAddress("XMR", "anything", ""); err != ErrAddressUnsupported {
+		t.Errorf("ValidateAddress returned %v, want ErrAddressUnsupported", err)
+	}
+}
+
+func TestValidateAddressValues(t *testing.T) {
+	if err := validateAddressValues("XMR", "anything", ""); err != nil {
+		t.Errorf("validateAddressValues for an unsupported currency returned %v, want nil", err)
+	}
+
+	err := validateAddressValues("BTC", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMJ", "")
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("validateAddressValues returned %v, want one ValidationError", err)
+	}
+	if errs[0].Field != "address" || errs[0].Reason != "address checksum mismatch" {
+		t.Errorf("validateAddressValues returned %+v, want an address checksum mismatch", errs[0])
+	}
+}
+
+func TestKeccak256(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+	}
+
+	for _, tt := range tests {
+		hash := keccak256([]byte(tt.input))
+		if got := hex.EncodeToString(hash[:]); got != tt.want {
+			t.Errorf("keccak256(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package coinpayments
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//amountPrecision is the number of decimal places the api uses for coin amounts
+const amountPrecision = 8
+
+//parseAmount parses a decimal amount string as sent and returned by the api
+func parseAmount(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("coinpayments: empty amount")
+	}
+	if strings.ContainsAny(s, "/eE") {
+		return nil, fmt.Errorf("coinpayments: malformed amount %q", s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("coinpayments: malformed amount %q", s)
+	}
+	return r, nil
+}
+
+//formatAmount formats an amount the way the api expects it, without trailing zeros
+func formatAmount(r *big.Rat) string {
+	s := r.FloatString(amountPrecision)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	return s
+}
@@ -0,0 +1,333 @@
+package coinpayments
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//WithdrawalState is the stage a staged withdrawal request is in
+type WithdrawalState string
+
+//Withdrawal request states
+const (
+	WithdrawalStaged WithdrawalState = "staged"
+	//WithdrawalSubmitting means the withdrawal is being sent to coinpayments
+	WithdrawalSubmitting WithdrawalState = "submitting"
+	WithdrawalSubmitted  WithdrawalState = "submitted"
+	//WithdrawalRevoking means a submitted withdrawal is being cancelled with coinpayments
+	WithdrawalRevoking WithdrawalState = "revoking"
+	WithdrawalRevoked  WithdrawalState = "revoked"
+	WithdrawalFailed   WithdrawalState = "failed"
+)
+
+//WithdrawalPolicy requires Approvers sign-offs for withdrawals of Currency at or above Threshold
+type WithdrawalPolicy struct {
+	//Currency the policy applies to, or empty to apply to every currency without its own policy
+	Currency string
+	//Threshold is the smallest amount the policy applies to, or empty to apply to every amount
+	Threshold string
+	Approvers int
+}
+
+//WithdrawalRequest is a withdrawal staged in a WithdrawalManager
+type WithdrawalRequest struct {
+	ID                string
+	Requester         string
+	Amount            string
+	Currency          string
+	State             WithdrawalState
+	RequiredApprovals int
+	Approvals         []string
+	WithdrawalID      string
+	WithdrawalStatus  int
+	Error             string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+
+	values url.Values
+}
+
+//WithdrawalManagerOption is an option used to modify a WithdrawalManager
+type WithdrawalManagerOption func(manager *WithdrawalManager)
+
+//WithdrawalManager stages withdrawals and only sends them once enough approvers have signed off
+type WithdrawalManager struct {
+	client      *Client
+	policies    []WithdrawalPolicy
+	autoConfirm bool
+
+	mu       sync.Mutex
+	requests map[string]*WithdrawalRequest
+}
+
+//NewWithdrawalManager returns a new WithdrawalManager with the applied options
+func NewWithdrawalManager(client *Client, options ...WithdrawalManagerOption) *WithdrawalManager {
+	manager := &WithdrawalManager{
+		client:   client,
+		requests: make(map[string]*WithdrawalRequest),
+	}
+
+	for _, o := range options {
+		o(manager)
+	}
+	return manager
+}
+
+//WithWithdrawalPolicy is an option that adds an approval policy to the WithdrawalManager
+func WithWithdrawalPolicy(policy WithdrawalPolicy) WithdrawalManagerOption {
+	return func(manager *WithdrawalManager) {
+		manager.policies = append(manager.policies, policy)
+	}
+}
+
+//WithAutoConfirm is an option that makes approved withdrawals skip the coinpayments email confirmation
+func WithAutoConfirm() WithdrawalManagerOption {
+	return func(manager *WithdrawalManager) {
+		manager.autoConfirm = true
+	}
+}
+
+//requiredApprovals returns the approver count of the strictest matching policy
+func (m *WithdrawalManager) requiredApprovals(amount, currency string) (int, error) {
+	want, err := parseAmount(amount)
+	if err != nil {
+		return 0, err
+	}
+
+	specific, fallback := 0, 0
+	hasSpecific := false
+	for _, p := range m.policies {
+		if p.Threshold != "" {
+			threshold, err := parseAmount(p.Threshold)
+			if err != nil {
+				return 0, fmt.Errorf("coinpayments: invalid policy threshold - %v", err)
+			}
+			if want.Cmp(threshold) < 0 {
+				continue
+			}
+		}
+
+		switch {
+		case strings.EqualFold(p.Currency, currency):
+			hasSpecific = true
+			if p.Approvers > specific {
+				specific = p.Approvers
+			}
+		case p.Currency == "":
+			if p.Approvers > fallback {
+				fallback = p.Approvers
+			}
+		}
+	}
+
+	if hasSpecific {
+		return specific, nil
+	}
+	return fallback, nil
+}
+
+//Stage records a withdrawal request, sending it immediately if no policy requires approval,
+//optionals may not set the amount or currency since those are what the policies check
+func (m *WithdrawalManager) Stage(requester, amount, currency string, optionals ...OptionalValue) (*WithdrawalRequest, error) {
+	values := url.Values{}
+	addOptionals(optionals, &values)
+	for _, key := range []string{"amount", "currency"} {
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("coinpayments: withdrawal %v must be passed to Stage, not as an optional value", key)
+		}
+	}
+
+	required, err := m.requiredApprovals(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	req := &WithdrawalRequest{
+		ID:                id,
+		Requester:         requester,
+		Amount:            amount,
+		Currency:          currency,
+		State:             WithdrawalStaged,
+		RequiredApprovals: required,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		values:            values,
+	}
+
+	if required == 0 {
+		req.State = WithdrawalSubmitting
+	}
+
+	m.mu.Lock()
+	m.requests[id] = req
+	staged := req.copy()
+	m.mu.Unlock()
+
+	if required > 0 {
+		return staged, nil
+	}
+	return m.submit(req), nil
+}
+
+//Approve records an approval, sending the withdrawal once the required number of approvers is reached
+func (m *WithdrawalManager) Approve(id, approver string) (*WithdrawalRequest, error) {
+	req, ready, err := m.approve(id, approver)
+	if err != nil || !ready {
+		return req, err
+	}
+	return m.submit(req), nil
+}
+
+//approve records an approval, returning the request marked as submitting if it is ready to send and a copy of it otherwise
+func (m *WithdrawalManager) approve(id, approver string) (*WithdrawalRequest, bool, error) {
+	if approver == "" {
+		return nil, false, fmt.Errorf("coinpayments: approver must not be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok {
+		return nil, false, fmt.Errorf("coinpayments: unknown withdrawal request %v", id)
+	}
+	if req.State != WithdrawalStaged {
+		return nil, false, fmt.Errorf("coinpayments: withdrawal request %v is %v", id, req.State)
+	}
+	if approver == req.Requester {
+		return nil, false, fmt.Errorf("coinpayments: requester cannot approve their own withdrawal")
+	}
+	for _, a := range req.Approvals {
+		if a == approver {
+			return nil, false, fmt.Errorf("coinpayments: %v has already approved withdrawal request %v", approver, id)
+		}
+	}
+
+	req.Approvals = append(req.Approvals, approver)
+	req.UpdatedAt = time.Now()
+
+	if len(req.Approvals) < req.RequiredApprovals {
+		return req.copy(), false, nil
+	}
+	req.State = WithdrawalSubmitting
+	return req, true, nil
+}
+
+//Revoke stops a withdrawal request, cancelling it with coinpayments if it was already sent
+func (m *WithdrawalManager) Revoke(id string) (*WithdrawalRequest, error) {
+	m.mu.Lock()
+	req, ok := m.requests[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("coinpayments: unknown withdrawal request %v", id)
+	}
+
+	switch req.State {
+	case WithdrawalStaged:
+		defer m.mu.Unlock()
+		req.State = WithdrawalRevoked
+		req.UpdatedAt = time.Now()
+		return req.copy(), nil
+	case WithdrawalSubmitted:
+		req.State = WithdrawalRevoking
+		m.mu.Unlock()
+	default:
+		defer m.mu.Unlock()
+		return nil, fmt.Errorf("coinpayments: withdrawal request %v is %v", id, req.State)
+	}
+
+	//the request is marked as revoking so it cannot be revoked twice while the call is made without holding m.mu
+	_, err := m.client.CancelWithdrawal(req.WithdrawalID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		req.State = WithdrawalSubmitted
+		return nil, err
+	}
+	req.State = WithdrawalRevoked
+	req.UpdatedAt = time.Now()
+	return req.copy(), nil
+}
+
+//Get returns a copy of the withdrawal request with the given id
+func (m *WithdrawalManager) Get(id string) (*WithdrawalRequest, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok {
+		return nil, false
+	}
+	return req.copy(), true
+}
+
+//Pending returns copies of the withdrawal requests still awaiting approval
+func (m *WithdrawalManager) Pending() []*WithdrawalRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []*WithdrawalRequest
+	for _, req := range m.requests {
+		if req.State == WithdrawalStaged {
+			pending = append(pending, req.copy())
+		}
+	}
+	return pending
+}
+
+//submit sends a request the caller has marked as submitting, without holding m.mu during the call, and returns a copy of the result
+func (m *WithdrawalManager) submit(req *WithdrawalRequest) *WithdrawalRequest {
+	optionals := []OptionalValue{func(values *url.Values) {
+		for key, v := range req.values {
+			(*values)[key] = v
+		}
+	}}
+	if m.autoConfirm {
+		optionals = append(optionals, WithOptionalValue("auto_confirm", "1"))
+	}
+
+	resp, err := m.client.CreateWithdrawal(req.Amount, req.Currency, optionals...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req.UpdatedAt = time.Now()
+	if err != nil {
+		req.State = WithdrawalFailed
+		req.Error = err.Error()
+		return req.copy()
+	}
+
+	req.State = WithdrawalSubmitted
+	req.WithdrawalID = resp.ID
+	req.WithdrawalStatus = resp.Status
+	return req.copy()
+}
+
+func (r *WithdrawalRequest) copy() *WithdrawalRequest {
+	c := *r
+	c.Approvals = append([]string(nil), r.Approvals...)
+	return &c
+}
+
+//newID returns a random identifier for locally tracked records
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("coinpayments: error generating id - %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
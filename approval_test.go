@@ -0,0 +1,134 @@
+package coinpayments
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequiredApprovals(t *testing.T) {
+	manager := NewWithdrawalManager(nil,
+		WithWithdrawalPolicy(WithdrawalPolicy{Approvers: 1}),
+		WithWithdrawalPolicy(WithdrawalPolicy{Currency: "BTC", Threshold: "1", Approvers: 2}),
+		WithWithdrawalPolicy(WithdrawalPolicy{Currency: "BTC", Threshold: "10", Approvers: 3}),
+	)
+
+	tests := []struct {
+		amount, currency string
+		want             int
+	}{
+		{"5", "LTC", 1},
+		{"0.5", "BTC", 1},
+		{"1", "BTC", 2},
+		{"10", "btc", 3},
+	}
+
+	for _, tt := range tests {
+		got, err := manager.requiredApprovals(tt.amount, tt.currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("requiredApprovals(%v, %v) = %v, want %v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestWithdrawalQuorum(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_withdrawal", `{"id":"W1","status":0,"amount":"2"}`)
+	manager := NewWithdrawalManager(api.client(), WithWithdrawalPolicy(WithdrawalPolicy{Approvers: 2}))
+
+	req, err := manager.Stage("alice", "2", "BTC", WithOptionalValue("address", "addr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.State != WithdrawalStaged || req.RequiredApprovals != 2 {
+		t.Fatalf("Stage returned %v needing %v approvals, want staged needing 2", req.State, req.RequiredApprovals)
+	}
+
+	for _, approver := range []string{"", "alice"} {
+		if _, err := manager.Approve(req.ID, approver); err == nil {
+			t.Errorf("Approve by %q returned nil error", approver)
+		}
+	}
+
+	if req, err = manager.Approve(req.ID, "bob"); err != nil || req.State != WithdrawalStaged {
+		t.Fatalf("first approval returned %v, %v, want still staged", req, err)
+	}
+	if _, err := manager.Approve(req.ID, "bob"); err == nil {
+		t.Error("a second approval by bob returned nil error")
+	}
+	if n := api.count("create_withdrawal"); n != 0 {
+		t.Fatalf("withdrawal sent after %v of 2 approvals", len(req.Approvals))
+	}
+
+	if req, err = manager.Approve(req.ID, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if req.State != WithdrawalSubmitted || req.WithdrawalID != "W1" {
+		t.Errorf("after quorum the request is %v with withdrawal %q, want submitted as W1", req.State, req.WithdrawalID)
+	}
+	values := api.calls[0]
+	if values.Get("amount") != "2" || values.Get("currency") != "BTC" || values.Get("address") != "addr" {
+		t.Errorf("create_withdrawal sent %v, want the staged amount, currency and address", values)
+	}
+}
+
+func TestWithdrawalRevoke(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_withdrawal", `{"id":"W1","status":0,"amount":"2"}`)
+	api.fail("cancel_withdrawal", "already sent")
+	manager := NewWithdrawalManager(api.client())
+
+	req, err := manager.Stage("alice", "2", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Revoke(req.ID); err == nil {
+		t.Fatal("Revoke returned nil error when cancel_withdrawal failed")
+	}
+	if req, _ = manager.Get(req.ID); req.State != WithdrawalSubmitted {
+		t.Fatalf("after a failed revoke the request is %v, want submitted", req.State)
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	api.hook = func(cmd string) {
+		if cmd == "cancel_withdrawal" {
+			close(entered)
+			<-release
+		}
+	}
+	api.set("cancel_withdrawal", `{}`)
+
+	done := make(chan error)
+	go func() {
+		_, err := manager.Revoke(req.ID)
+		done <- err
+	}()
+	<-entered
+
+	got := make(chan *WithdrawalRequest)
+	go func() {
+		req, _ := manager.Get(req.ID)
+		got <- req
+	}()
+	select {
+	case req := <-got:
+		if req.State != WithdrawalRevoking {
+			t.Errorf("while cancelling the request is %v, want revoking", req.State)
+		}
+	case <-time.After(time.Second):
+		t.Error("Get blocked while Revoke was cancelling the withdrawal")
+	}
+	if _, err := manager.Revoke(req.ID); err == nil {
+		t.Error("a second Revoke while cancelling returned nil error")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if req, _ = manager.Get(req.ID); req.State != WithdrawalRevoked {
+		t.Errorf("after Revoke the request is %v, want revoked", req.State)
+	}
+}
@@ -0,0 +1,215 @@
+package coinpayments
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+//ArchivedIPN is a raw IPN as it was received, kept so it can be replayed later
+type ArchivedIPN struct {
+	ID         string      `json:"id"`
+	ReceivedAt time.Time   `json:"received_at"`
+	IPNType    string      `json:"ipn_type"`
+	TxnID      string      `json:"txn_id,omitempty"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+	//Verified is set if the HMAC was checked against the IPN secret when the IPN was received
+	Verified bool `json:"verified"`
+}
+
+//IPNFilter selects archived IPNs, zero fields match everything
+type IPNFilter struct {
+	Types []string
+	Since time.Time
+	Until time.Time
+	TxnID string
+}
+
+//Match reports whether the archived IPN passes the filter
+func (f IPNFilter) Match(record *ArchivedIPN) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == record.IPNType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && record.ReceivedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.ReceivedAt.Before(f.Until) {
+		return false
+	}
+	return f.TxnID == "" || f.TxnID == record.TxnID
+}
+
+//IPNArchive stores raw IPNs for later replay
+type IPNArchive interface {
+	Archive(record *ArchivedIPN) error
+	Records(filter IPNFilter) ([]*ArchivedIPN, error)
+}
+
+//FileIPNArchive is an IPNArchive appending one JSON object per IPN to a file
+type FileIPNArchive struct {
+	path string
+	mu   sync.Mutex
+}
+
+//NewFileIPNArchive returns a FileIPNArchive writing to path, which is created on first use
+func NewFileIPNArchive(path string) *FileIPNArchive {
+	return &FileIPNArchive{path: path}
+}
+
+//Archive appends the record to the archive file
+func (a *FileIPNArchive) Archive(record *ArchivedIPN) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error marshaling archived IPN - %v", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error opening IPN archive - %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("coinpayments: error writing IPN archive - %v", err)
+	}
+	return f.Sync()
+}
+
+//Records returns the archived IPNs matching the filter, in the order they were received
+func (a *FileIPNArchive) Records(filter IPNFilter) ([]*ArchivedIPN, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("coinpayments: error opening IPN archive - %v", err)
+	}
+	defer f.Close()
+
+	var records []*ArchivedIPN
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record ArchivedIPN
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("coinpayments: error unmarshaling archived IPN - %v", err)
+		}
+		if filter.Match(&record) {
+			records = append(records, &record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("coinpayments: error reading IPN archive - %v", err)
+	}
+	return records, nil
+}
+
+//WithIPNArchive is an option that makes ParseIPN archive every raw IPN it parses successfully
+func WithIPNArchive(archive IPNArchive) ClientOption {
+	return func(client *Client) {
+		client.ipnArchive = archive
+	}
+}
+
+func (c *Client) archiveIPN(data []byte, header http.Header, verified bool) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	values, _ := url.ParseQuery(string(data))
+	record := &ArchivedIPN{
+		ID:         id,
+		ReceivedAt: time.Now(),
+		IPNType:    values.Get("ipn_type"),
+		TxnID:      values.Get("txn_id"),
+		Header:     header.Clone(),
+		Body:       string(data),
+		Verified:   verified,
+	}
+	if err := c.ipnArchive.Archive(record); err != nil {
+		return fmt.Errorf("coinpayments: error archiving IPN - %v", err)
+	}
+	return nil
+}
+
+//ReplayOption is an option used to modify how ReplayIPNs replays archived IPNs
+type ReplayOption func(replay *replayConfig)
+
+type replayConfig struct {
+	skipVerification bool
+}
+
+//WithoutVerification is an option that replays archived IPNs without VerifyArchivedIPN,
+//for archives recorded by a client that had no IPN secret and so could not verify them
+func WithoutVerification() ReplayOption {
+	return func(replay *replayConfig) {
+		replay.skipVerification = true
+	}
+}
+
+//ReplayIPNs parses archived IPNs matching the filter and hands them to the handler, refusing IPNs that fail VerifyArchivedIPN
+//unless WithoutVerification is used
+func (c *Client) ReplayIPNs(archive IPNArchive, handler *IPNHandler, filter IPNFilter, options ...ReplayOption) (int, error) {
+	var config replayConfig
+	for _, o := range options {
+		o(&config)
+	}
+
+	records, err := archive.Records(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, record := range records {
+		var ipn *IPN
+		if config.skipVerification {
+			ipn, err = decodeIPN([]byte(record.Body))
+		} else {
+			ipn, err = c.parseArchivedIPN(record)
+		}
+		if err != nil {
+			return i, fmt.Errorf("coinpayments: error replaying IPN %v - %v", record.ID, err)
+		}
+		if err := handler.Handle(ipn); err != nil {
+			return i, fmt.Errorf("coinpayments: error replaying IPN %v - %v", record.ID, err)
+		}
+	}
+	return len(records), nil
+}
+
+//VerifyArchivedIPN checks that an archived IPN was verified when it was received and that its HMAC matches the client's IPN secret
+func (c *Client) VerifyArchivedIPN(record *ArchivedIPN) error {
+	_, err := c.parseArchivedIPN(record)
+	return err
+}
+
+func (c *Client) parseArchivedIPN(record *ArchivedIPN) (*IPN, error) {
+	if !record.Verified {
+		return nil, fmt.Errorf("coinpayments: archived IPN %v was not verified when it was received", record.ID)
+	}
+	if c.ipnSecret == "" {
+		return nil, fmt.Errorf("coinpayments: an IPN secret is required to verify archived IPN %v", record.ID)
+	}
+	return c.parseIPN([]byte(record.Body), record.Header.Get("HMAC"))
+}
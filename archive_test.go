@@ -0,0 +1,99 @@
+package coinpayments
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memoryIPNArchive struct {
+	mu      sync.Mutex
+	records []*ArchivedIPN
+}
+
+func (a *memoryIPNArchive) Archive(record *ArchivedIPN) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, record)
+	return nil
+}
+
+func (a *memoryIPNArchive) Records(filter IPNFilter) ([]*ArchivedIPN, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var records []*ArchivedIPN
+	for _, r := range a.records {
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+const testIPNBody = "ipn_version=1.0&ipn_type=api&ipn_mode=hmac&ipn_id=1&merchant=m&txn_id=T1&status=100&status_text=Complete&currency1=USD&currency2=BTC&amount1=10&amount2=0.001&fee=0.00001"
+
+func receiveTestIPN(t *testing.T, client *Client) {
+	req := httptest.NewRequest("POST", "/ipn", strings.NewReader(testIPNBody))
+	if client.ipnSecret != "" {
+		hmac, err := client.makeIPNHMAC(testIPNBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("HMAC", hmac)
+	}
+	if _, err := client.ParseIPN(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayIPNsVerified(t *testing.T) {
+	archive := &memoryIPNArchive{}
+	receiveTestIPN(t, NewClient("", "", WithIPNSecret("secret"), WithIPNArchive(archive)))
+	if len(archive.records) != 1 || !archive.records[0].Verified {
+		t.Fatalf("archived %+v, want one verified record", archive.records)
+	}
+
+	var handled []string
+	handler := NewIPNHandler(nil, WithIPNFunc(func(ipn *IPN) error {
+		handled = append(handled, ipn.IPNType)
+		return nil
+	}))
+
+	n, err := NewClient("", "", WithIPNSecret("secret")).ReplayIPNs(archive, handler, IPNFilter{})
+	if err != nil || n != 1 || len(handled) != 1 {
+		t.Fatalf("ReplayIPNs returned %v, %v and handled %v, want one IPN", n, err, handled)
+	}
+
+	if _, err := NewClient("", "", WithIPNSecret("other")).ReplayIPNs(archive, handler, IPNFilter{}); err == nil {
+		t.Error("ReplayIPNs with the wrong IPN secret returned nil error")
+	}
+}
+
+func TestReplayIPNsUnverified(t *testing.T) {
+	archive := &memoryIPNArchive{}
+	receiveTestIPN(t, NewClient("", "", WithIPNArchive(archive)))
+	if len(archive.records) != 1 || archive.records[0].Verified {
+		t.Fatalf("archived %+v, want one unverified record", archive.records)
+	}
+
+	var handled int
+	handler := NewIPNHandler(nil, WithIPNFunc(func(ipn *IPN) error {
+		handled++
+		return nil
+	}))
+
+	client := NewClient("", "", WithIPNSecret("secret"))
+	if _, err := client.ReplayIPNs(archive, handler, IPNFilter{}); err == nil {
+		t.Error("ReplayIPNs of an unverified record returned nil error")
+	}
+	if err := client.VerifyArchivedIPN(archive.records[0]); err == nil {
+		t.Error("VerifyArchivedIPN of an unverified record returned nil error")
+	}
+
+	n, err := NewClient("", "").ReplayIPNs(archive, handler, IPNFilter{}, WithoutVerification())
+	if err != nil || n != 1 || handled != 1 {
+		t.Errorf("ReplayIPNs WithoutVerification returned %v, %v and handled %v, want one IPN", n, err, handled)
+	}
+}
@@ -0,0 +1,335 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ConversionPolicy converts every completed receipt of From into To
+type ConversionPolicy struct {
+	From string
+	To   string
+}
+
+//ConversionRecord is the history of a single conversion submitted by a ConversionEngine or followed by a ConversionTracker
+type ConversionRecord struct {
+	ID          string
+	From        string
+	To          string
+	Amount      string
+	Received    string
+	Rate        string
+	Status      int
+	StatusText  string
+	Complete    bool
+	Failed      bool
+	SubmittedAt time.Time
+	CompletedAt time.Time
+	AmountSent  string
+	//ExpectedRate is the market rate when the conversion was submitted, empty if unknown
+	ExpectedRate string
+	//Slippage is the fraction by which Rate fell short of ExpectedRate, negative if it was better
+	Slippage string
+}
+
+//Done reports whether the conversion has completed or failed
+func (r ConversionRecord) Done() bool {
+	return r.Complete || r.Failed
+}
+
+//ConversionEngineOption is an option used to modify a ConversionEngine
+type ConversionEngineOption func(engine *ConversionEngine)
+
+//ConversionEngine converts received coins according to policies, batching amounts below the conversion minimum
+type ConversionEngine struct {
+	client     *Client
+	tracker    *ConversionTracker
+	policies   map[string]ConversionPolicy
+	onComplete []func(record ConversionRecord)
+	onError    ErrorFunc
+	capacity   int
+
+	mu        sync.Mutex
+	pending   map[string]*big.Rat
+	processed map[string]bool
+	order     []string
+}
+
+//NewConversionEngine returns a new ConversionEngine with the applied options
+func NewConversionEngine(client *Client, options ...ConversionEngineOption) *ConversionEngine {
+	engine := &ConversionEngine{
+		client:    client,
+		policies:  make(map[string]ConversionPolicy),
+		capacity:  defaultDecidedCapacity,
+		pending:   make(map[string]*big.Rat),
+		processed: make(map[string]bool),
+	}
+
+	for _, o := range options {
+		o(engine)
+	}
+
+	engine.tracker = NewConversionTracker(client, WithConversionEventFunc(engine.finished))
+	return engine
+}
+
+//WithConversionPolicy is an option that adds a conversion policy, replacing any earlier policy for the same source coin
+func WithConversionPolicy(policy ConversionPolicy) ConversionEngineOption {
+	return func(engine *ConversionEngine) {
+		engine.policies[strings.ToUpper(policy.From)] = policy
+	}
+}
+
+//WithConversionCompleteFunc is an option that registers a callback for finished conversions
+func WithConversionCompleteFunc(fn func(record ConversionRecord)) ConversionEngineOption {
+	return func(engine *ConversionEngine) {
+		engine.onComplete = append(engine.onComplete, fn)
+	}
+}
+
+//WithProcessedCapacity is an option that sets how many txn_ids QueueTx remembers to queue each only once,
+//the oldest are forgotten first so a much later IPN for one of them is queued again
+func WithProcessedCapacity(capacity int) ConversionEngineOption {
+	return func(engine *ConversionEngine) {
+		engine.capacity = capacity
+	}
+}
+
+//WithConversionEngineErrorFunc is an option that receives the errors Run handles without stopping
+func WithConversionEngineErrorFunc(fn ErrorFunc) ConversionEngineOption {
+	return func(engine *ConversionEngine) {
+		engine.onError = fn
+	}
+}
+
+//HandleIPN queues the net amount of completed "deposit" and "api" IPNs for conversion, once per txn_id,
+//using the received amount of an "api" IPN when coinpayments reports one since the buyer may have paid more or less than asked
+func (e *ConversionEngine) HandleIPN(ipn *IPN) error {
+	var txnID, status, coin, amount, fee string
+	switch ipn.IPNType {
+	case "deposit":
+		info := ipn.depositInformation
+		txnID, status, coin, amount, fee = info.TransactionID, info.Status, info.Currency, info.Amount, info.Fee
+	case "api":
+		info := ipn.apiGeneratedTransactionFields
+		txnID, status, coin, amount, fee = info.TransactionID, info.Status, info.Currency2, info.Amount2, info.Fee
+		if info.ReceivedAmount != "" {
+			amount = info.ReceivedAmount
+		}
+	default:
+		return nil
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid %v status %q - %v", ipn.IPNType, status, err)
+	}
+	if !paymentComplete(code) {
+		return nil
+	}
+
+	net, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if fee != "" {
+		f, err := parseAmount(fee)
+		if err != nil {
+			return err
+		}
+		net.Sub(net, f)
+	}
+
+	return e.QueueTx(txnID, coin, formatAmount(net))
+}
+
+//Queue adds an amount of a coin to be converted on the next Process, use QueueTx for amounts that may be delivered more than once
+func (e *ConversionEngine) Queue(coin, amount string) error {
+	return e.QueueTx("", coin, amount)
+}
+
+//QueueTx adds an amount of a coin to be converted on the next Process, ignoring a txnID that was already queued
+func (e *ConversionEngine) QueueTx(txnID, coin, amount string) error {
+	coin = strings.ToUpper(coin)
+	if _, ok := e.policies[coin]; !ok {
+		return nil
+	}
+
+	a, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if txnID != "" {
+		if e.processed[txnID] {
+			return nil
+		}
+		e.processed[txnID] = true
+		e.order = append(e.order, txnID)
+		for len(e.order) > e.capacity {
+			delete(e.processed, e.order[0])
+			e.order = e.order[1:]
+		}
+	}
+	e.queue(coin, a)
+	return nil
+}
+
+//queue adds to the pending amount of a coin, the caller must hold e.mu
+func (e *ConversionEngine) queue(coin string, amount *big.Rat) {
+	if e.pending[coin] == nil {
+		e.pending[coin] = new(big.Rat)
+	}
+	e.pending[coin].Add(e.pending[coin], amount)
+}
+
+//Pending returns the queued amount per coin that has not yet been converted
+func (e *ConversionEngine) Pending() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pending := make(map[string]string, len(e.pending))
+	for coin, a := range e.pending {
+		pending[coin] = formatAmount(a)
+	}
+	return pending
+}
+
+//Process submits conversions for queued amounts within the conversion limits, keeping smaller amounts queued,
+//a failing coin stays queued without stopping the others and its error is returned in WorkerErrors
+func (e *ConversionEngine) Process() ([]ConversionRecord, error) {
+	return e.ProcessContext(context.Background())
+}
+
+//ProcessContext submits conversions for queued amounts within the conversion limits with the context, keeping smaller amounts queued
+func (e *ConversionEngine) ProcessContext(ctx context.Context) ([]ConversionRecord, error) {
+	var submitted []ConversionRecord
+	var errs WorkerErrors
+	for _, coin := range e.queuedCoins() {
+		policy := e.policies[coin]
+
+		limits, err := e.client.ConvertLimitsContext(ctx, policy.From, policy.To)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("coinpayments: error converting %v - %v", coin, err))
+			continue
+		}
+
+		send := e.take(coin, limits)
+		if send == nil {
+			continue
+		}
+
+		record, err := e.tracker.SubmitContext(ctx, formatAmount(send), policy.From, policy.To)
+		if err != nil {
+			e.mu.Lock()
+			e.queue(coin, send)
+			e.mu.Unlock()
+			errs = append(errs, fmt.Errorf("coinpayments: error converting %v - %v", coin, err))
+			continue
+		}
+		submitted = append(submitted, *record)
+	}
+	return submitted, errs.err()
+}
+
+//queuedCoins returns the coins with a positive queued amount
+func (e *ConversionEngine) queuedCoins() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var coins []string
+	for coin, amount := range e.pending {
+		if amount.Sign() > 0 {
+			coins = append(coins, coin)
+		}
+	}
+	return coins
+}
+
+//take removes and returns the amount of a coin to convert within the limits, or nil if the queued amount is below the minimum
+func (e *ConversionEngine) take(coin string, limits *convertLimitsResponse) *big.Rat {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	amount := e.pending[coin]
+	if amount == nil || amount.Sign() <= 0 {
+		return nil
+	}
+
+	send := new(big.Rat).Set(amount)
+	if min, err := parseAmount(limits.Min); err == nil && send.Cmp(min) < 0 {
+		return nil
+	}
+	if max, err := parseAmount(limits.Max); err == nil && max.Sign() > 0 && send.Cmp(max) > 0 {
+		send = max
+	}
+	amount.Sub(amount, send)
+	return send
+}
+
+//Poll follows submitted conversions, returning failed amounts to the queue
+func (e *ConversionEngine) Poll() error {
+	return e.tracker.Poll()
+}
+
+//PollContext follows submitted conversions with the context, returning failed amounts to the queue
+func (e *ConversionEngine) PollContext(ctx context.Context) error {
+	return e.tracker.PollContext(ctx)
+}
+
+//Records returns the conversions submitted by the engine, with realized rates for completed ones
+func (e *ConversionEngine) Records() []ConversionRecord {
+	return e.tracker.List()
+}
+
+//Run processes the queue and polls conversions on the given interval until the context is done,
+//reporting errors to the error func or the client's logger
+func (e *ConversionEngine) Run(ctx context.Context, interval time.Duration) error {
+	return e.client.runEvery(ctx, interval, func(ctx context.Context) error {
+		var errs WorkerErrors
+		if _, err := e.ProcessContext(ctx); err != nil {
+			errs = appendErrors(errs, err)
+		}
+		if err := e.PollContext(ctx); err != nil {
+			errs = appendErrors(errs, err)
+		}
+		return errs.err()
+	}, e.onError)
+}
+
+func (e *ConversionEngine) finished(record ConversionRecord) {
+	if record.Failed {
+		if a, err := parseAmount(record.Amount); err == nil {
+			e.mu.Lock()
+			e.queue(record.From, a)
+			e.mu.Unlock()
+		}
+	}
+
+	for _, fn := range e.onComplete {
+		fn(record)
+	}
+}
+
+//paymentComplete reports whether a payment status means the funds are final
+func paymentComplete(status int) bool {
+	return status >= 100 || status == 2
+}
+
+//conversionComplete reports whether a conversion status means the coins were received
+func conversionComplete(status int) bool {
+	return status >= 100 || status == 2
+}
+
+//conversionFailed reports whether a conversion status means the conversion will not complete
+func conversionFailed(status int) bool {
+	return status < 0
+}
@@ -0,0 +1,70 @@
+package coinpayments
+
+import (
+	"testing"
+)
+
+func TestConversionEngineHandleIPN(t *testing.T) {
+	engine := NewConversionEngine(nil, WithConversionPolicy(ConversionPolicy{From: "btc", To: "LTC"}))
+
+	ipns := []*IPN{
+		//queued net of the fee, from the received amount when there is one
+		newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T1", "currency2", "BTC", "amount2", "1", "received_amount", "1.5", "fee", "0.01"),
+		newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T2", "currency2", "BTC", "amount2", "0.5", "fee", "0.01"),
+		newTestIPN(t, "ipn_type", "deposit", "status", "100", "txn_id", "D1", "currency", "BTC", "amount", "0.25", "fee", "0.01"),
+		//ignored as a repeat, unfinished or without a policy
+		newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T1", "currency2", "BTC", "amount2", "1", "received_amount", "1.5", "fee", "0.01"),
+		newTestIPN(t, "ipn_type", "api", "status", "1", "txn_id", "T3", "currency2", "BTC", "amount2", "1"),
+		newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T4", "currency2", "DOGE", "amount2", "1"),
+	}
+	for _, ipn := range ipns {
+		if err := engine.HandleIPN(ipn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if pending := engine.Pending(); len(pending) != 1 || pending["BTC"] != "2.22" {
+		t.Errorf("Pending returned %v, want 2.22 BTC", pending)
+	}
+}
+
+func TestConversionEngineForgetsOldestTxns(t *testing.T) {
+	engine := NewConversionEngine(nil, WithConversionPolicy(ConversionPolicy{From: "BTC", To: "LTC"}), WithProcessedCapacity(2))
+
+	for _, txnID := range []string{"A", "B", "C", "A", "C"} {
+		if err := engine.QueueTx(txnID, "BTC", "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pending := engine.Pending(); pending["BTC"] != "4" {
+		t.Errorf("Pending returned %v, want 4 BTC after A was forgotten and queued again", pending)
+	}
+	if len(engine.processed) != 2 || len(engine.order) != 2 {
+		t.Errorf("engine remembers %v txns in an order of %v, want 2", len(engine.processed), len(engine.order))
+	}
+}
+
+func TestConversionEngineBatchesBelowMinimum(t *testing.T) {
+	api := newFakeAPI()
+	api.set("convert_limits", `{"min":"1","max":"2"}`)
+	api.set("rates", `{"BTC":{"rate_btc":"1"},"LTC":{"rate_btc":"0.01"}}`)
+	api.set("convert", `{"id":"C1"}`)
+	engine := NewConversionEngine(api.client(), WithConversionPolicy(ConversionPolicy{From: "BTC", To: "LTC"}))
+
+	engine.Queue("BTC", "0.5")
+	if records, err := engine.Process(); err != nil || len(records) != 0 {
+		t.Fatalf("Process below the minimum returned %v, %v, want nothing submitted", records, err)
+	}
+
+	engine.Queue("BTC", "2")
+	records, err := engine.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Amount != "2" {
+		t.Fatalf("Process returned %+v, want one conversion of the 2 BTC maximum", records)
+	}
+	if pending := engine.Pending(); pending["BTC"] != "0.5" {
+		t.Errorf("Pending returned %v, want 0.5 BTC above the maximum kept queued", pending)
+	}
+}
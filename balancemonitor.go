@@ -0,0 +1,292 @@
+package coinpayments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//BalanceRule alerts when a coin balance drops below Below or grows above Above, either may be left empty
+type BalanceRule struct {
+	Currency string
+	Below    string
+	Above    string
+}
+
+//BalanceAlertKind is the condition that triggered a BalanceAlert
+type BalanceAlertKind string
+
+//Balance alert kinds
+const (
+	BalanceBelow BalanceAlertKind = "below"
+	BalanceAbove BalanceAlertKind = "above"
+)
+
+//BalanceAlert is sent to notifiers when a balance crosses a configured threshold
+type BalanceAlert struct {
+	Currency  string           `json:"currency"`
+	Kind      BalanceAlertKind `json:"kind"`
+	Balance   string           `json:"balance"`
+	Threshold string           `json:"threshold"`
+	//Delta is the change since the previous poll, empty on the first poll since there is nothing to compare with
+	Delta string    `json:"delta,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+//BalanceNotifier delivers balance alerts
+type BalanceNotifier interface {
+	Notify(alert BalanceAlert) error
+}
+
+//BalanceNotifierFunc adapts a function to a BalanceNotifier
+type BalanceNotifierFunc func(alert BalanceAlert) error
+
+//Notify calls f(alert)
+func (f BalanceNotifierFunc) Notify(alert BalanceAlert) error {
+	return f(alert)
+}
+
+//WebhookNotifier posts balance alerts as JSON to a url
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+//NewWebhookNotifier returns a WebhookNotifier posting to the url with the given http client, or http.DefaultClient if nil
+func NewWebhookNotifier(url string, httpClient *http.Client) *WebhookNotifier {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &WebhookNotifier{url: url, client: httpClient}
+}
+
+//Notify posts the alert to the webhook url
+func (w *WebhookNotifier) Notify(alert BalanceAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error marshaling balance alert - %v", err)
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("coinpayments: error posting balance alert - %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("coinpayments: balance webhook returned unexpected status: %v", resp.StatusCode)
+	}
+	return nil
+}
+
+//BalanceMonitorOption is an option used to modify a BalanceMonitor
+type BalanceMonitorOption func(monitor *BalanceMonitor)
+
+//BalanceMonitor polls "balances" and alerts when coins cross configured thresholds
+type BalanceMonitor struct {
+	client    *Client
+	rules     []BalanceRule
+	notifiers []BalanceNotifier
+	onError   ErrorFunc
+
+	mu     sync.Mutex
+	last   map[string]*big.Rat
+	deltas map[string]*big.Rat
+	//delivered holds, for each crossed threshold, the indexes of the notifiers that have its alert
+	delivered map[string]map[int]bool
+}
+
+//NewBalanceMonitor returns a new BalanceMonitor with the applied options
+func NewBalanceMonitor(client *Client, options ...BalanceMonitorOption) *BalanceMonitor {
+	monitor := &BalanceMonitor{
+		client:    client,
+		delivered: make(map[string]map[int]bool),
+	}
+
+	for _, o := range options {
+		o(monitor)
+	}
+	return monitor
+}
+
+//WithBalanceRule is an option that adds a threshold rule to the BalanceMonitor
+func WithBalanceRule(rule BalanceRule) BalanceMonitorOption {
+	return func(monitor *BalanceMonitor) {
+		monitor.rules = append(monitor.rules, rule)
+	}
+}
+
+//WithBalanceNotifier is an option that adds a notifier to the BalanceMonitor
+func WithBalanceNotifier(notifier BalanceNotifier) BalanceMonitorOption {
+	return func(monitor *BalanceMonitor) {
+		monitor.notifiers = append(monitor.notifiers, notifier)
+	}
+}
+
+//WithBalanceMonitorErrorFunc is an option that receives the errors Run handles without stopping
+func WithBalanceMonitorErrorFunc(fn ErrorFunc) BalanceMonitorOption {
+	return func(monitor *BalanceMonitor) {
+		monitor.onError = fn
+	}
+}
+
+//Deltas returns the change in each coin balance between the last two polls, empty until the second poll
+func (m *BalanceMonitor) Deltas() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deltas := make(map[string]string, len(m.deltas))
+	for coin, d := range m.deltas {
+		deltas[coin] = formatAmount(d)
+	}
+	return deltas
+}
+
+func (m *BalanceMonitor) balances(ctx context.Context) (map[string]*big.Rat, error) {
+	resp, err := m.client.BalancesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*big.Rat, len(*resp))
+	for coin, b := range *resp {
+		amount, err := parseAmount(b.Balancef)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error parsing %v balance - %v", coin, err)
+		}
+		balances[strings.ToUpper(coin)] = amount
+	}
+	return balances, nil
+}
+
+//Poll fetches balances once, evaluates the rules and notifies about newly crossed thresholds,
+//an alert is sent again on the next Poll to the notifiers that failed to deliver it, not to the ones that did
+func (m *BalanceMonitor) Poll() ([]BalanceAlert, error) {
+	return m.PollContext(context.Background())
+}
+
+//PollContext fetches balances once with the context, evaluates the rules and notifies about newly crossed thresholds
+func (m *BalanceMonitor) PollContext(ctx context.Context) ([]BalanceAlert, error) {
+	balances, err := m.balances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	//the first poll has no earlier balances, so it has no deltas
+	var deltas map[string]*big.Rat
+	if m.last != nil {
+		deltas = make(map[string]*big.Rat, len(balances))
+		for coin, balance := range balances {
+			deltas[coin] = new(big.Rat).Set(balance)
+		}
+		for coin, last := range m.last {
+			if _, ok := deltas[coin]; !ok {
+				deltas[coin] = new(big.Rat)
+			}
+			deltas[coin].Sub(deltas[coin], last)
+		}
+	}
+
+	now := time.Now()
+	var alerts []BalanceAlert
+	var pending [][]int
+	for _, rule := range m.rules {
+		coin := strings.ToUpper(rule.Currency)
+		balance, ok := balances[coin]
+		if !ok {
+			balance = new(big.Rat)
+		}
+
+		delta := ""
+		if deltas != nil {
+			delta = "0"
+			if d, ok := deltas[coin]; ok {
+				delta = formatAmount(d)
+			}
+		}
+
+		for _, check := range []struct {
+			kind      BalanceAlertKind
+			threshold string
+			sign      int
+		}{
+			{BalanceBelow, rule.Below, -1},
+			{BalanceAbove, rule.Above, 1},
+		} {
+			if check.threshold == "" {
+				continue
+			}
+			threshold, err := parseAmount(check.threshold)
+			if err != nil {
+				m.mu.Unlock()
+				return nil, fmt.Errorf("coinpayments: invalid %v threshold for %v - %v", check.kind, coin, err)
+			}
+
+			key := coin + "/" + string(check.kind)
+			if balance.Cmp(threshold) != check.sign {
+				delete(m.delivered, key)
+				continue
+			}
+
+			delivered, ok := m.delivered[key]
+			if !ok {
+				delivered = make(map[int]bool)
+				m.delivered[key] = delivered
+			} else if len(delivered) == len(m.notifiers) {
+				continue
+			}
+
+			var notifiers []int
+			for i := range m.notifiers {
+				if !delivered[i] {
+					notifiers = append(notifiers, i)
+				}
+			}
+			alerts = append(alerts, BalanceAlert{
+				Currency:  coin,
+				Kind:      check.kind,
+				Balance:   formatAmount(balance),
+				Threshold: check.threshold,
+				Delta:     delta,
+				Time:      now,
+			})
+			pending = append(pending, notifiers)
+		}
+	}
+	m.last = balances
+	m.deltas = deltas
+	m.mu.Unlock()
+
+	var errs WorkerErrors
+	for i, alert := range alerts {
+		key := alert.Currency + "/" + string(alert.Kind)
+		for _, n := range pending[i] {
+			if err := m.notifiers[n].Notify(alert); err != nil {
+				errs = append(errs, fmt.Errorf("coinpayments: error notifying %v %v alert - %v", alert.Currency, alert.Kind, err))
+				continue
+			}
+
+			m.mu.Lock()
+			if delivered, ok := m.delivered[key]; ok {
+				delivered[n] = true
+			}
+			m.mu.Unlock()
+		}
+	}
+	return alerts, errs.err()
+}
+
+//Run polls on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (m *BalanceMonitor) Run(ctx context.Context, interval time.Duration) error {
+	return m.client.runEvery(ctx, interval, func(ctx context.Context) error {
+		_, err := m.PollContext(ctx)
+		return err
+	}, m.onError)
+}
@@ -0,0 +1,111 @@
+package coinpayments
+
+import (
+	"errors"
+	"testing"
+)
+
+func balancesResult(btc string) string {
+	return `{"BTC":{"balancef":"` + btc + `","status":"available"}}`
+}
+
+func TestBalanceMonitorThresholds(t *testing.T) {
+	api := newFakeAPI()
+	var sent []BalanceAlert
+	monitor := NewBalanceMonitor(api.client(),
+		WithBalanceRule(BalanceRule{Currency: "btc", Below: "1", Above: "5"}),
+		WithBalanceNotifier(BalanceNotifierFunc(func(alert BalanceAlert) error {
+			sent = append(sent, alert)
+			return nil
+		})),
+	)
+
+	tests := []struct {
+		balance string
+		kinds   []BalanceAlertKind
+		delta   string
+	}{
+		{"0.5", []BalanceAlertKind{BalanceBelow}, ""},
+		{"0.25", nil, ""},
+		{"2", nil, ""},
+		{"0.75", []BalanceAlertKind{BalanceBelow}, "-1.25"},
+		{"6", []BalanceAlertKind{BalanceAbove}, "5.25"},
+	}
+
+	for i, tt := range tests {
+		api.set("balances", balancesResult(tt.balance))
+		sent = nil
+		alerts, err := monitor.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != len(tt.kinds) || len(sent) != len(tt.kinds) {
+			t.Fatalf("poll %v of %v returned alerts %+v and sent %v, want %v", i, tt.balance, alerts, len(sent), tt.kinds)
+		}
+		for j, kind := range tt.kinds {
+			if alerts[j].Kind != kind || alerts[j].Currency != "BTC" || alerts[j].Balance != tt.balance || alerts[j].Delta != tt.delta {
+				t.Errorf("poll %v alert is %+v, want %v of %v with delta %q", i, alerts[j], kind, tt.balance, tt.delta)
+			}
+		}
+	}
+
+	if deltas := monitor.Deltas(); deltas["BTC"] != "5.25" {
+		t.Errorf("Deltas returned %v, want a BTC delta of 5.25", deltas)
+	}
+}
+
+func TestBalanceMonitorFirstPollHasNoDelta(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", balancesResult("2"))
+	monitor := NewBalanceMonitor(api.client())
+
+	if _, err := monitor.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if deltas := monitor.Deltas(); len(deltas) != 0 {
+		t.Errorf("after the first poll Deltas returned %v, want none", deltas)
+	}
+
+	api.set("balances", balancesResult("2.5"))
+	if _, err := monitor.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if deltas := monitor.Deltas(); deltas["BTC"] != "0.5" {
+		t.Errorf("after the second poll Deltas returned %v, want a BTC delta of 0.5", deltas)
+	}
+}
+
+func TestBalanceMonitorRetriesOnlyFailedNotifiers(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", balancesResult("0.5"))
+
+	var good, bad int
+	failing := true
+	monitor := NewBalanceMonitor(api.client(),
+		WithBalanceRule(BalanceRule{Currency: "BTC", Below: "1"}),
+		WithBalanceNotifier(BalanceNotifierFunc(func(alert BalanceAlert) error {
+			good++
+			return nil
+		})),
+		WithBalanceNotifier(BalanceNotifierFunc(func(alert BalanceAlert) error {
+			bad++
+			if failing {
+				return errors.New("down")
+			}
+			return nil
+		})),
+	)
+
+	if _, err := monitor.Poll(); err == nil {
+		t.Fatal("Poll returned nil error for a failed notifier")
+	}
+	failing = false
+	for i := 0; i < 2; i++ {
+		if _, err := monitor.Poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if good != 1 || bad != 2 {
+		t.Errorf("notifiers were called %v and %v times, want 1 for the working one and 2 for the failing one", good, bad)
+	}
+}
@@ -0,0 +1,221 @@
+package coinpayments
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+//Button is a checkout form for one of the coinpayments payment buttons
+type Button struct {
+	client *Client
+	values url.Values
+}
+
+//WithMerchantID is an option that sets the merchant id used by payment buttons, instead of looking it up with "get_basic_info"
+func WithMerchantID(id string) ClientOption {
+	return func(client *Client) {
+		client.merchantID = id
+	}
+}
+
+//WithItem is an optional value that sets the item name and number
+func WithItem(name, number string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("item_name", name)
+		if number != "" {
+			values.Set("item_number", number)
+		}
+	}
+}
+
+//WithQuantity is an optional value that sets the item quantity, allowing the buyer to change it if allowChange is set
+func WithQuantity(quantity int, allowChange bool) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("quantity", fmt.Sprint(quantity))
+		if allowChange {
+			values.Set("allow_quantity", "1")
+		}
+	}
+}
+
+//WithTax is an optional value that sets the tax amount of a button
+func WithTax(amount string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("taxf", amount)
+	}
+}
+
+//WithShipping is an optional value that sets the shipping cost of a button and the cost of each additional item
+func WithShipping(amount, additional string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("want_shipping", "1")
+		values.Set("shippingf", amount)
+		if additional != "" {
+			values.Set("shipping2f", additional)
+		}
+	}
+}
+
+//WithItemOption is an optional value that sets the name and value of item option 1 or 2
+func WithItemOption(n int, name, value string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set(fmt.Sprintf("on%v", n), name)
+		values.Set(fmt.Sprintf("ov%v", n), value)
+	}
+}
+
+//WithInvoice is an optional value that sets the invoice field passed back in IPNs
+func WithInvoice(invoice string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("invoice", invoice)
+	}
+}
+
+//WithCustom is an optional value that sets the custom field passed back in IPNs
+func WithCustom(custom string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("custom", custom)
+	}
+}
+
+//WithIPNURL is an optional value that sets the url IPNs are sent to
+func WithIPNURL(ipnURL string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("ipn_url", ipnURL)
+	}
+}
+
+//WithSuccessURL is an optional value that sets where the buyer is sent after paying
+func WithSuccessURL(successURL string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("success_url", successURL)
+	}
+}
+
+//WithCancelURL is an optional value that sets where the buyer is sent if they cancel
+func WithCancelURL(cancelURL string) OptionalValue {
+	return func(values *url.Values) {
+		values.Set("cancel_url", cancelURL)
+	}
+}
+
+//SimpleButton returns a "simple" payment button for a single item
+func (c *Client) SimpleButton(currency, amount, itemName string, optionals ...OptionalValue) (*Button, error) {
+	return c.newButton("_pay_simple", currency, amount, append([]OptionalValue{WithItem(itemName, "")}, optionals...))
+}
+
+//AdvancedButton returns an advanced "button" payment button for a single item
+func (c *Client) AdvancedButton(currency, amount, itemName string, optionals ...OptionalValue) (*Button, error) {
+	return c.newButton("_pay", currency, amount, append([]OptionalValue{WithItem(itemName, "")}, optionals...))
+}
+
+//CartButton returns a "cart" button that adds an item to the buyer's shopping cart
+func (c *Client) CartButton(currency, amount, itemName string, optionals ...OptionalValue) (*Button, error) {
+	return c.newButton("_cart_add", currency, amount, append([]OptionalValue{WithItem(itemName, "")}, optionals...))
+}
+
+//DonationButton returns a "donation" button, an empty amount lets the donor choose it
+func (c *Client) DonationButton(currency, amount, itemName string, optionals ...OptionalValue) (*Button, error) {
+	if amount == "" {
+		optionals = append(optionals, WithOptionalValue("allow_amount", "1"))
+	}
+	return c.newButton("_donate", currency, amount, append([]OptionalValue{WithItem(itemName, "")}, optionals...))
+}
+
+func (c *Client) newButton(cmd, currency, amount string, optionals []OptionalValue) (*Button, error) {
+	if amount != "" {
+		if _, err := parseAmount(amount); err != nil {
+			return nil, err
+		}
+	}
+
+	merchant, err := c.merchant()
+	if err != nil {
+		return nil, err
+	}
+
+	values := &url.Values{}
+	values.Set("cmd", cmd)
+	values.Set("reset", "1")
+	values.Set("merchant", merchant)
+	values.Set("currency", currency)
+	if amount != "" {
+		values.Set("amountf", amount)
+	}
+	addOptionals(optionals, values)
+
+	return &Button{client: c, values: *values}, nil
+}
+
+//merchant returns the configured merchant id, looking it up with "get_basic_info" and caching it if it was not set
+func (c *Client) merchant() (string, error) {
+	c.mu.Lock()
+	id := c.merchantID
+	c.mu.Unlock()
+	if id != "" {
+		return id, nil
+	}
+
+	info, err := c.GetBasicInfo()
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.merchantID = info.MerchantID
+	return c.merchantID, nil
+}
+
+//Values returns a copy of the form fields of the button
+func (b *Button) Values() url.Values {
+	values := make(url.Values, len(b.values))
+	for k, v := range b.values {
+		values[k] = append([]string(nil), v...)
+	}
+	return values
+}
+
+//Sign adds an "hmac" field over the other fields, keyed with the client's IPN secret
+func (b *Button) Sign() error {
+	if b.client.ipnSecret == "" {
+		return fmt.Errorf("coinpayments: signing a button requires an IPN secret")
+	}
+
+	values := b.Values()
+	values.Del("hmac")
+	mac, err := b.client.makeIPNHMAC(values.Encode())
+	if err != nil {
+		return fmt.Errorf("coinpayments: error making button HMAC - %v", err)
+	}
+	b.values.Set("hmac", mac)
+	return nil
+}
+
+//URL returns a checkout link that opens the button's payment page
+func (b *Button) URL() string {
+	return checkoutURL + "?" + b.values.Encode()
+}
+
+//HTML returns a form that posts the button to the checkout, submitted with a button showing label
+func (b *Button) HTML(label string) string {
+	keys := make([]string, 0, len(b.values))
+	for k := range b.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<form action=\"%v\" method=\"post\">\n", checkoutURL)
+	for _, k := range keys {
+		for _, v := range b.values[k] {
+			fmt.Fprintf(&sb, "\t<input type=\"hidden\" name=\"%v\" value=\"%v\">\n", html.EscapeString(k), html.EscapeString(v))
+		}
+	}
+	fmt.Fprintf(&sb, "\t<input type=\"submit\" value=\"%v\">\n", html.EscapeString(label))
+	sb.WriteString("</form>\n")
+	return sb.String()
+}
@@ -1,6 +1,7 @@
 package coinpayments
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/json"
@@ -10,6 +11,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 //ClientOption is an option used to modify a client
@@ -24,6 +27,18 @@ type Client struct {
 	privateKey string
 	publicKey  string
 	ipnSecret  string
+	preflight  bool
+
+	validateAddresses bool
+	ipnArchive        IPNArchive
+	metrics           Metrics
+	tracer            Tracer
+	logger            *loggerConfig
+	middleware        []Middleware
+	handler           CallFunc
+
+	mu         sync.Mutex
+	merchantID string
 }
 
 //NewClient returns a new Client with the applied options
@@ -32,11 +47,17 @@ func NewClient(publicKey, privateKey string, options ...ClientOption) *Client {
 		privateKey: privateKey,
 		publicKey:  publicKey,
 		client:     http.DefaultClient,
+		tracer:     NoopTracer{},
 	}
 
 	for _, o := range options {
 		o(client)
 	}
+
+	client.handler = client.send
+	for i := len(client.middleware) - 1; i >= 0; i-- {
+		client.handler = client.middleware[i](client.handler)
+	}
 	return client
 }
 
@@ -61,8 +82,50 @@ func WithOptionalValue(key, value string) OptionalValue {
 	}
 }
 
-func (c *Client) call(cmd string, values *url.Values, response interface{}) error {
+func (c *Client) callContext(ctx context.Context, cmd string, values *url.Values, response interface{}) error {
+	ctx, span := c.tracer.Start(ctx, "coinpayments."+cmd, commandAttributes(cmd, *values)...)
+
+	var params string
+	if c.logger != nil {
+		params = c.logger.params(*values)
+	}
+
+	start := time.Now()
+	category, err := c.do(ctx, cmd, values, response)
+	latency := time.Since(start)
+	if c.metrics != nil {
+		c.metrics.ObserveCall(cmd, latency, category)
+	}
+	if c.logger != nil {
+		c.logger.logCall(ctx, cmd, params, latency, category, err)
+	}
+
+	span.SetAttributes(resultAttribute(category))
+	span.End(err)
+	return err
+}
+
+//do runs the api request through the middleware chain and decodes the result, returning the category of any error for instrumentation
+func (c *Client) do(ctx context.Context, cmd string, values *url.Values, response interface{}) (ErrorCategory, error) {
+	body, err := c.handler(ctx, cmd, *values)
+	if err != nil {
+		return categorize(err)
+	}
 
+	err = json.Unmarshal(body, response)
+	if err != nil {
+		return ErrorDecode, fmt.Errorf("coinpayments: error unmarshaling response json - %v", err)
+	}
+
+	return ErrorNone, nil
+}
+
+//send signs and sends the api request, it is the innermost CallFunc of the middleware chain
+func (c *Client) send(ctx context.Context, cmd string, params url.Values) ([]byte, error) {
+	values := make(url.Values, len(params)+4)
+	for k, v := range params {
+		values[k] = append([]string(nil), v...)
+	}
 	values.Add("key", c.publicKey)
 	values.Add("version", apiVersion)
 	values.Add("cmd", cmd)
@@ -72,12 +135,12 @@ func (c *Client) call(cmd string, values *url.Values, response interface{}) erro
 
 	dataHMAC, err := c.makeHMAC(sData)
 	if err != nil {
-		return fmt.Errorf("coinpayments: error making HMAC - %v", err)
+		return nil, &categorizedError{ErrorRequest, fmt.Errorf("coinpayments: error making HMAC - %v", err)}
 	}
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(sData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(sData))
 	if err != nil {
-		return fmt.Errorf("coinpayments: error making api request - %v", err)
+		return nil, &categorizedError{ErrorRequest, fmt.Errorf("coinpayments: error making api request - %v", err)}
 	}
 
 	req.Header.Add("HMAC", dataHMAC)
@@ -88,35 +151,23 @@ func (c *Client) call(cmd string, values *url.Values, response interface{}) erro
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("coinpayments: error doing api request - %v", err)
+		return nil, &categorizedError{ErrorTransport, fmt.Errorf("coinpayments: error doing api request - %v", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("coinpayments: api call returned unexpected status: %v", resp.StatusCode)
+		return nil, &categorizedError{ErrorHTTPStatus, fmt.Errorf("coinpayments: api call returned unexpected status: %v", resp.StatusCode)}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("coinpayments: error reading api response body - %v", err)
+		return nil, &categorizedError{ErrorTransport, fmt.Errorf("coinpayments: error reading api response body - %v", err)}
 	}
 
-	var errResp errResponse
-
-	if err := json.Unmarshal(body, &errResp); err != nil {
-		return fmt.Errorf("coinpayments: error unmarshaling api error response - %v", err)
+	if _, err := DecodeEnvelope(body); err != nil {
+		return body, err
 	}
-
-	if errResp.Error != apiSuccess {
-		return fmt.Errorf("coinpayments: api error - %v", errResp.Error)
-	}
-
-	err = json.Unmarshal(body, response)
-	if err != nil {
-		return fmt.Errorf("coinpayments: error unmarshaling response json - %v", err)
-	}
-
-	return nil
+	return body, nil
 }
 
 func (c *Client) makeHMAC(data string) (string, error) {
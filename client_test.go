@@ -0,0 +1,146 @@
+package coinpayments
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//fakeAPI is an http.RoundTripper answering api calls with canned results keyed by command
+type fakeAPI struct {
+	mu      sync.Mutex
+	results map[string]string
+	errors  map[string]string
+	calls   []url.Values
+	//hook, if set, is called with each command before it is answered
+	hook func(cmd string)
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{results: make(map[string]string), errors: make(map[string]string)}
+}
+
+//set makes cmd succeed with the json result
+func (f *fakeAPI) set(cmd, result string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[cmd] = result
+	delete(f.errors, cmd)
+}
+
+//fail makes cmd return the api error
+func (f *fakeAPI) fail(cmd, apiError string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[cmd] = apiError
+}
+
+//count returns how many times cmd was called
+func (f *fakeAPI) count(cmd string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, values := range f.calls {
+		if values.Get("cmd") == cmd {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := values.Get("cmd")
+	if f.hook != nil {
+		f.hook(cmd)
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, values)
+	result, ok := f.results[cmd]
+	apiError, failed := f.errors[cmd]
+	f.mu.Unlock()
+
+	envelope := `{"error":"ok","result":` + result + `}`
+	switch {
+	case failed:
+		envelope = `{"error":"` + apiError + `","result":[]}`
+	case !ok:
+		envelope = `{"error":"unexpected command ` + cmd + `","result":[]}`
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(envelope)),
+		Request:    req,
+	}, nil
+}
+
+//client returns a Client whose api calls are answered by the fake
+func (f *fakeAPI) client(options ...ClientOption) *Client {
+	options = append([]ClientOption{WithHTTPClient(&http.Client{Transport: f})}, options...)
+	return NewClient("public", "private", options...)
+}
+
+func TestCallSignsAndDecodes(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", `{"BTC":{"balance":150000000,"balancef":"1.50000000","status":"available"}}`)
+
+	balances, err := api.client().BalancesContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*balances)["BTC"].Balancef != "1.50000000" {
+		t.Errorf("Balances returned %+v, want a BTC balance of 1.5", *balances)
+	}
+
+	values := api.calls[0]
+	if values.Get("key") != "public" || values.Get("version") != apiVersion || values.Get("format") != apiFormat {
+		t.Errorf("request values %v are missing the key, version or format", values)
+	}
+}
+
+//newTestIPN decodes an IPN from key and value pairs, such as "ipn_type", "api", "status", "100"
+func newTestIPN(t *testing.T, pairs ...string) *IPN {
+	values := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		values.Set(pairs[i], pairs[i+1])
+	}
+
+	ipn, err := decodeIPN([]byte(values.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ipn
+}
+
+func TestGetTxInfoMultiDecodesReceived(t *testing.T) {
+	api := newFakeAPI()
+	api.set("get_tx_info_multi", `{"T1":{"error":"ok","receivedf":"0.5","recv_confirms":3}}`)
+
+	infos, err := api.client().GetTxInfoMulti("T1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := (*infos)["T1"]; info.Recievedf != "0.5" || info.RecievedConfirms != 3 {
+		t.Errorf("GetTxInfoMulti returned %+v, want 0.5 received with 3 confirms", info)
+	}
+}
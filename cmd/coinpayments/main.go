@@ -0,0 +1,297 @@
+//Command coinpayments operates a coinpayments.net account from the command line
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aidenesco/coinpayments"
+)
+
+const usage = `usage: coinpayments <command> [arguments] [flags]
+
+commands:
+  balances                                      show coin balances
+  rates                                         show exchange rates
+  tx info <txid>                                show a transaction
+  tx create <amount> <currency1> <currency2> <buyer email>
+                                                create a transaction
+  withdraw <amount> <currency> [address]        withdraw coins, to -pbntag if no address
+  withdraw cancel <id>                          cancel a withdrawal awaiting confirmation
+  convert <amount> <from> <to>                  convert coins
+  pbn list                                      list $PayByName tags
+  ipn replay -archive <file> -url <ipn url>     post archived IPNs to an IPN endpoint again
+
+credentials are read from COINPAYMENTS_PUBLIC_KEY and COINPAYMENTS_PRIVATE_KEY,
+or from public_key and private_key lines in the -config file (default ~/.coinpayments),
+ipn replay reads COINPAYMENTS_IPN_SECRET or ipn_secret the same way to verify archived IPNs,
+unless -no-verify is given for archives recorded without an IPN secret
+
+flags may come before or after the arguments, arguments after -- are never read as flags,
+run "coinpayments <command> -h" for the flags of a command
+`
+
+//options are the flags shared by every command
+type options struct {
+	config  string
+	format  string
+	yes     bool
+	skip    bool
+	pbntag  string
+	destTag string
+	set     setFlag
+
+	archive  string
+	target   string
+	types    string
+	since    string
+	until    string
+	txnID    string
+	noVerify bool
+}
+
+//setFlag collects repeated -set key=value flags as optional api values
+type setFlag []coinpayments.OptionalValue
+
+func (s *setFlag) String() string {
+	return ""
+}
+
+func (s *setFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 1 {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	*s = append(*s, coinpayments.WithOptionalValue(value[:i], value[i+1:]))
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "coinpayments:", strings.TrimPrefix(err.Error(), "coinpayments: "))
+		os.Exit(1)
+	}
+}
+
+//run runs a command, writing results to stdout and prompts to stderr so results can be piped
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+
+	name := args[0]
+	args = args[1:]
+	if len(args) > 0 && (name == "tx" || name == "pbn" || name == "ipn" || name == "withdraw" && args[0] == "cancel") {
+		name += " " + args[0]
+		args = args[1:]
+	}
+
+	var opts options
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.config, "config", "", "config file with public_key and private_key lines")
+	flags.StringVar(&opts.format, "format", "table", "output format: table, json or csv")
+	flags.BoolVar(&opts.yes, "yes", false, "do not ask for confirmation before moving funds")
+	flags.BoolVar(&opts.skip, "skip-validation", false, "do not validate addresses and balances before withdrawing or converting")
+	flags.StringVar(&opts.pbntag, "pbntag", "", "withdraw to a $PayByName tag instead of an address")
+	flags.StringVar(&opts.destTag, "dest-tag", "", "destination tag for coins that use one")
+	flags.Var(&opts.set, "set", "extra api `key=value`, may be repeated")
+	flags.StringVar(&opts.archive, "archive", "", "IPN archive file to replay from")
+	flags.StringVar(&opts.target, "url", "", "IPN endpoint to replay to")
+	flags.StringVar(&opts.types, "type", "", "only replay these comma separated IPN types")
+	flags.StringVar(&opts.since, "since", "", "only replay IPNs received at or after this RFC 3339 time or date")
+	flags.StringVar(&opts.until, "until", "", "only replay IPNs received before this RFC 3339 time or date")
+	flags.StringVar(&opts.txnID, "txn", "", "only replay IPNs for this transaction id")
+	flags.BoolVar(&opts.noVerify, "no-verify", false, "replay archived IPNs without verifying them, for archives recorded without an IPN secret")
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if name == "ipn replay" {
+		return replay(opts, stdout)
+	}
+
+	config, err := readConfig(opts.config)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(opts, config)
+	if err != nil {
+		return err
+	}
+
+	confirm := func(format string, a ...interface{}) error {
+		if opts.yes {
+			return nil
+		}
+		fmt.Fprintf(stderr, format+" [y/N] ", a...)
+		answer, _ := bufio.NewReader(stdin).ReadString('\n')
+		if s := strings.ToLower(strings.TrimSpace(answer)); s != "y" && s != "yes" {
+			return fmt.Errorf("aborted")
+		}
+		return nil
+	}
+
+	var result interface{}
+	switch name {
+	case "balances":
+		result, err = client.Balances(opts.set...)
+	case "rates":
+		result, err = client.Rates(opts.set...)
+	case "tx info":
+		if err := wantArgs(args, "<txid>"); err != nil {
+			return err
+		}
+		result, err = client.GetTxInfo(args[0], opts.set...)
+	case "tx create":
+		if err := wantArgs(args, "<amount>", "<currency1>", "<currency2>", "<buyer email>"); err != nil {
+			return err
+		}
+		result, err = client.CreateTransaction(args[0], args[1], args[2], args[3], opts.set...)
+	case "withdraw":
+		if len(args) == 2 && opts.pbntag != "" {
+			args = append(args, "")
+		}
+		if err := wantArgs(args, "<amount>", "<currency>", "<address>"); err != nil {
+			return err
+		}
+		optionals := opts.set
+		destination := args[2]
+		if opts.pbntag != "" {
+			optionals = append(optionals, coinpayments.WithOptionalValue("pbntag", opts.pbntag))
+			destination = opts.pbntag
+		}
+		if args[2] != "" {
+			optionals = append(optionals, coinpayments.WithOptionalValue("address", args[2]))
+		}
+		if opts.destTag != "" {
+			optionals = append(optionals, coinpayments.WithOptionalValue("dest_tag", opts.destTag))
+		}
+		if !opts.skip && args[2] != "" {
+			if err := coinpayments.ValidateAddress(args[1], args[2], opts.destTag); err != nil && err != coinpayments.ErrAddressUnsupported {
+				return err
+			}
+		}
+		if err := confirm("withdraw %v %v to %v?", args[0], args[1], destination); err != nil {
+			return err
+		}
+		result, err = client.CreateWithdrawal(args[0], args[1], optionals...)
+	case "withdraw cancel":
+		if err := wantArgs(args, "<id>"); err != nil {
+			return err
+		}
+		if err := confirm("cancel withdrawal %v?", args[0]); err != nil {
+			return err
+		}
+		result, err = client.CancelWithdrawal(args[0], opts.set...)
+	case "convert":
+		if err := wantArgs(args, "<amount>", "<from>", "<to>"); err != nil {
+			return err
+		}
+		if err := confirm("convert %v %v to %v?", args[0], args[1], args[2]); err != nil {
+			return err
+		}
+		result, err = client.Convert(args[0], args[1], args[2], opts.set...)
+	case "pbn list":
+		result, err = client.GetPBNList(opts.set...)
+	default:
+		return fmt.Errorf("unknown command %q, run \"coinpayments help\" for usage", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	return render(stdout, opts.format, result)
+}
+
+//parseFlags parses flags wherever they appear among the arguments and returns the arguments,
+//since the flag package stops at the first argument and would ignore the -yes in "withdraw 1 BTC addr -yes"
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		//the flag package stops after a "--" terminator, everything left is an argument
+		if parsed := len(args) - flags.NArg(); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, flags.Args()...), nil
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func wantArgs(args []string, names ...string) error {
+	if len(args) != len(names) {
+		return fmt.Errorf("expected arguments %v", strings.Join(names, " "))
+	}
+	return nil
+}
+
+//readConfig reads key=value lines from the config file, a missing default config file is not an error
+func readConfig(path string) (map[string]string, error) {
+	config := map[string]string{}
+
+	explicit := path != ""
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return config, nil
+		}
+		path = filepath.Join(home, ".coinpayments")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if explicit || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading config - %v", err)
+		}
+		return config, nil
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			config[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return config, nil
+}
+
+//setting returns an environment variable, falling back to the config file
+func setting(config map[string]string, env, key string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return config[key]
+}
+
+//newClient builds a client from the environment, falling back to the config file
+func newClient(opts options, config map[string]string) (*coinpayments.Client, error) {
+	publicKey := setting(config, "COINPAYMENTS_PUBLIC_KEY", "public_key")
+	privateKey := setting(config, "COINPAYMENTS_PRIVATE_KEY", "private_key")
+	if publicKey == "" || privateKey == "" {
+		return nil, fmt.Errorf("missing credentials, set COINPAYMENTS_PUBLIC_KEY and COINPAYMENTS_PRIVATE_KEY")
+	}
+
+	var clientOptions []coinpayments.ClientOption
+	if !opts.skip {
+		clientOptions = append(clientOptions, coinpayments.WithAddressValidation(), coinpayments.WithPreflightValidation())
+	}
+	return coinpayments.NewClient(publicKey, privateKey, clientOptions...), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+		yes  bool
+		tag  string
+	}{
+		{[]string{"-yes", "1", "BTC", "addr"}, []string{"1", "BTC", "addr"}, true, ""},
+		{[]string{"1", "BTC", "addr", "-yes"}, []string{"1", "BTC", "addr"}, true, ""},
+		{[]string{"1", "-dest-tag", "7", "BTC", "addr"}, []string{"1", "BTC", "addr"}, false, "7"},
+		{[]string{"1", "--", "-yes", "addr"}, []string{"1", "-yes", "addr"}, false, ""},
+		{nil, nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		yes := flags.Bool("yes", false, "")
+		tag := flags.String("dest-tag", "", "")
+
+		got, err := parseFlags(flags, tt.args)
+		if err != nil {
+			t.Fatalf("parseFlags(%q) returned error %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) || *yes != tt.yes || *tag != tt.tag {
+			t.Errorf("parseFlags(%q) = %q with yes %v and dest tag %q, want %q, %v and %q", tt.args, got, *yes, *tag, tt.want, tt.yes, tt.tag)
+		}
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	if _, err := parseFlags(flags, []string{"1", "-unknown"}); err == nil {
+		t.Error("parseFlags of an unknown trailing flag returned nil error")
+	}
+}
+
+func writeTestConfig(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "coinpayments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte("# keys\npublic_key = pub\nprivate_key=priv\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfig(t *testing.T) {
+	config, err := readConfig(writeTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["public_key"] != "pub" || config["private_key"] != "priv" || len(config) != 2 {
+		t.Errorf("readConfig returned %v, want the two keys", config)
+	}
+
+	if _, err := readConfig(filepath.Join(os.TempDir(), "coinpayments-missing-config")); err == nil {
+		t.Error("readConfig of a missing explicit config returned nil error")
+	}
+}
+
+func TestConfirmPromptsOnStderr(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"withdraw", "1", "BTC", "addr", "-skip-validation", "-config", writeTestConfig(t)}
+
+	err := run(args, strings.NewReader("n\n"), &stdout, &stderr)
+	if err == nil || err.Error() != "aborted" {
+		t.Fatalf("run returned %v, want aborted", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("run wrote %q to stdout, want nothing", stdout.String())
+	}
+	if want := "withdraw 1 BTC to addr? [y/N] "; stderr.String() != want {
+		t.Errorf("run prompted %q on stderr, want %q", stderr.String(), want)
+	}
+}
+
+func TestRunArguments(t *testing.T) {
+	config := writeTestConfig(t)
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"tx", "info", "-config", config}, "expected arguments <txid>"},
+		{[]string{"convert", "1", "BTC", "-config", config}, "expected arguments <amount> <from> <to>"},
+		{[]string{"withdraw", "1", "BTC", "addr", "-bogus"}, "flag provided but not defined: -bogus"},
+		{[]string{"nope", "-config", config}, `unknown command "nope", run "coinpayments help" for usage`},
+	}
+
+	for _, tt := range tests {
+		err := run(tt.args, strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("run(%q) returned %v, want %v", tt.args, err, tt.err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := run([]string{"help"}, nil, &stdout, ioutil.Discard); err != nil || !strings.HasPrefix(stdout.String(), "usage:") {
+		t.Errorf("run help returned %v and printed %q, want the usage", err, stdout.String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+//render writes an api result in the requested format
+func render(w io.Writer, format string, result interface{}) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	header, rows, err := tabulate(result)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, row := range append([][]string{header}, rows...) {
+			for i, cell := range row {
+				if i > 0 {
+					fmt.Fprint(tw, "\t")
+				}
+				fmt.Fprint(tw, cell)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+//tabulate flattens a result into rows: maps of objects get a key column, lists one row per element, objects a single row
+func tabulate(result interface{}) ([]string, [][]string, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, nil, err
+	}
+
+	var records []map[string]interface{}
+	keyed := false
+	switch v := generic.(type) {
+	case []interface{}:
+		for _, e := range v {
+			records = append(records, asRecord(e))
+		}
+	case map[string]interface{}:
+		keyed = len(v) > 0
+		for _, e := range v {
+			if _, ok := e.(map[string]interface{}); !ok {
+				keyed = false
+			}
+		}
+		if !keyed {
+			records = []map[string]interface{}{v}
+		} else {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				record := asRecord(v[k])
+				record["key"] = k
+				records = append(records, record)
+			}
+		}
+	default:
+		records = []map[string]interface{}{asRecord(v)}
+	}
+
+	columns := map[string]bool{}
+	for _, r := range records {
+		for k := range r {
+			columns[k] = true
+		}
+	}
+	var header []string
+	for k := range columns {
+		if k != "key" {
+			header = append(header, k)
+		}
+	}
+	sort.Strings(header)
+	if keyed {
+		header = append([]string{"key"}, header...)
+	}
+
+	rows := make([][]string, len(records))
+	for i, r := range records {
+		row := make([]string, len(header))
+		for j, k := range header {
+			row[j] = cell(r[k])
+		}
+		rows[i] = row
+	}
+	return header, rows, nil
+}
+
+func asRecord(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{"value": v}
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aidenesco/coinpayments"
+)
+
+//replay posts archived IPNs to an IPN endpoint, keeping their original headers, after verifying them with the IPN secret unless -no-verify is set
+func replay(opts options, stdout io.Writer) error {
+	if opts.archive == "" || opts.target == "" {
+		return fmt.Errorf("ipn replay needs -archive and -url")
+	}
+
+	filter := coinpayments.IPNFilter{TxnID: opts.txnID}
+	if opts.types != "" {
+		filter.Types = strings.Split(opts.types, ",")
+	}
+	var err error
+	if filter.Since, err = parseTime(opts.since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseTime(opts.until); err != nil {
+		return err
+	}
+
+	config, err := readConfig(opts.config)
+	if err != nil {
+		return err
+	}
+	secret := setting(config, "COINPAYMENTS_IPN_SECRET", "ipn_secret")
+	if secret == "" && !opts.noVerify {
+		return fmt.Errorf("ipn replay needs COINPAYMENTS_IPN_SECRET to verify archived IPNs, or -no-verify")
+	}
+	client := coinpayments.NewClient("", "", coinpayments.WithIPNSecret(secret))
+
+	records, err := coinpayments.NewFileIPNArchive(opts.archive).Records(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if !opts.noVerify {
+			if err := client.VerifyArchivedIPN(record); err != nil {
+				return err
+			}
+		}
+
+		req, err := http.NewRequest("POST", opts.target, strings.NewReader(record.Body))
+		if err != nil {
+			return err
+		}
+		for k, v := range record.Header {
+			req.Header[k] = v
+		}
+		req.Header.Del("Content-Length")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("error replaying IPN %v - %v", record.ID, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		fmt.Fprintf(stdout, "%v\t%v\t%v\t%v\n", record.ID, record.IPNType, resp.StatusCode, strings.TrimSpace(string(body)))
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("IPN %v was rejected with status %v", record.ID, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+//parseTime parses an RFC 3339 time or a plain date, returning the zero time for an empty string
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
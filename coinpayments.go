@@ -1,8 +1,9 @@
 package coinpayments
 
 const (
-	apiURL     = "https://www.coinpayments.net/api.php"
-	apiFormat  = "json"
-	apiSuccess = "ok"
-	apiVersion = "1"
+	apiURL      = "https://www.coinpayments.net/api.php"
+	apiFormat   = "json"
+	apiSuccess  = "ok"
+	apiVersion  = "1"
+	checkoutURL = "https://www.coinpayments.net/index.php"
 )
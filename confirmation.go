@@ -0,0 +1,113 @@
+package coinpayments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//ConfirmationDecision is what to do with a payment at its current confirmation count
+type ConfirmationDecision string
+
+//Confirmation decisions
+const (
+	ConfirmationAccept ConfirmationDecision = "accept"
+	ConfirmationWait   ConfirmationDecision = "wait"
+	ConfirmationReject ConfirmationDecision = "reject"
+)
+
+//ConfirmationRule accepts payments of Currency up to MaxAmount once they have MinConfirms confirmations
+type ConfirmationRule struct {
+	//Currency the rule applies to, or empty for every currency
+	Currency string
+	//MaxAmount is the largest coin amount the rule applies to, or empty for any amount
+	MaxAmount   string
+	MinConfirms int
+	//Reject makes matching payments be rejected instead of accepted early
+	Reject bool
+}
+
+//ConfirmationPolicy decides when payments are confirmed enough to act on, using the first matching rule
+type ConfirmationPolicy struct {
+	rules []ConfirmationRule
+}
+
+//NewConfirmationPolicy returns a ConfirmationPolicy evaluating rules in order
+func NewConfirmationPolicy(rules ...ConfirmationRule) *ConfirmationPolicy {
+	return &ConfirmationPolicy{rules: rules}
+}
+
+//Decide returns the decision for a pending payment of amount coin with confirms confirmations, payments no rule matches wait for coinpayments
+func (p *ConfirmationPolicy) Decide(coin, amount string, confirms int) (ConfirmationDecision, error) {
+	value, err := parseAmount(amount)
+	if err != nil {
+		return ConfirmationWait, err
+	}
+
+	for _, rule := range p.rules {
+		if rule.Currency != "" && !strings.EqualFold(rule.Currency, coin) {
+			continue
+		}
+		if rule.MaxAmount != "" {
+			max, err := parseAmount(rule.MaxAmount)
+			if err != nil {
+				return ConfirmationWait, fmt.Errorf("coinpayments: invalid confirmation rule amount - %v", err)
+			}
+			if value.Cmp(max) > 0 {
+				continue
+			}
+		}
+
+		switch {
+		case rule.Reject:
+			return ConfirmationReject, nil
+		case confirms >= rule.MinConfirms:
+			return ConfirmationAccept, nil
+		default:
+			return ConfirmationWait, nil
+		}
+	}
+	return ConfirmationWait, nil
+}
+
+//DecideIPN returns the decision for a payment or deposit IPN, complete payments are always accepted and cancelled ones rejected
+func (p *ConfirmationPolicy) DecideIPN(ipn *IPN) (ConfirmationDecision, error) {
+	var status, coin, expected, received, confirms string
+	if payment, ok := ipn.payment(); ok {
+		status, coin, expected, received, confirms = payment.Status, payment.Currency2, payment.Amount2, payment.ReceivedAmount, payment.ReceivedConfirms
+	} else if ipn.IPNType == "deposit" {
+		info := ipn.depositInformation
+		status, coin, expected, received, confirms = info.Status, info.Currency, info.Amount, info.Amount, info.Confirms
+	} else {
+		return ConfirmationWait, fmt.Errorf("coinpayments: IPN type '%v' has no confirmations", ipn.IPNType)
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return ConfirmationWait, fmt.Errorf("coinpayments: invalid status %q - %v", status, err)
+	}
+	switch {
+	case code < 0:
+		return ConfirmationReject, nil
+	case paymentComplete(code):
+		return ConfirmationAccept, nil
+	}
+
+	want, err := parseAmount(expected)
+	if err != nil {
+		return ConfirmationWait, err
+	}
+	if received == "" {
+		return ConfirmationWait, nil
+	}
+	got, err := parseAmount(received)
+	if err != nil {
+		return ConfirmationWait, err
+	}
+	if got.Cmp(want) < 0 {
+		return ConfirmationWait, nil
+	}
+
+	n, _ := strconv.Atoi(confirms)
+	return p.Decide(coin, expected, n)
+}
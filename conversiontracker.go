@@ -0,0 +1,274 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ConversionTrackerOption is an option used to modify a ConversionTracker
+type ConversionTrackerOption func(tracker *ConversionTracker)
+
+//ConversionTracker follows conversions through "get_conversion_info" until they finish
+type ConversionTracker struct {
+	client  *Client
+	events  []func(record ConversionRecord)
+	onError ErrorFunc
+
+	mu          sync.Mutex
+	conversions map[string]*ConversionRecord
+	done        map[string]chan struct{}
+}
+
+//NewConversionTracker returns a new ConversionTracker with the applied options
+func NewConversionTracker(client *Client, options ...ConversionTrackerOption) *ConversionTracker {
+	tracker := &ConversionTracker{
+		client:      client,
+		conversions: make(map[string]*ConversionRecord),
+		done:        make(map[string]chan struct{}),
+	}
+
+	for _, o := range options {
+		o(tracker)
+	}
+	return tracker
+}
+
+//WithConversionEventFunc is an option that registers a callback for conversions that complete or fail
+func WithConversionEventFunc(fn func(record ConversionRecord)) ConversionTrackerOption {
+	return func(tracker *ConversionTracker) {
+		tracker.events = append(tracker.events, fn)
+	}
+}
+
+//WithConversionTrackerErrorFunc is an option that receives the errors Run handles without stopping
+func WithConversionTrackerErrorFunc(fn ErrorFunc) ConversionTrackerOption {
+	return func(tracker *ConversionTracker) {
+		tracker.onError = fn
+	}
+}
+
+//Submit calls the "convert" command and tracks the conversion, recording the market rate at submission
+func (t *ConversionTracker) Submit(amount, from, to string, optionals ...OptionalValue) (*ConversionRecord, error) {
+	return t.SubmitContext(context.Background(), amount, from, to, optionals...)
+}
+
+//SubmitContext calls the "convert" command with the context and tracks the conversion, recording the market rate at submission
+func (t *ConversionTracker) SubmitContext(ctx context.Context, amount, from, to string, optionals ...OptionalValue) (*ConversionRecord, error) {
+	expected, err := t.marketRate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.client.ConvertContext(ctx, amount, from, to, optionals...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := t.Track(resp.ID, from, to, amount, expected)
+	return &result, nil
+}
+
+//Track starts tracking a conversion submitted elsewhere, expectedRate may be empty if unknown
+func (t *ConversionTracker) Track(id, from, to, amount, expectedRate string) ConversionRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r, ok := t.conversions[id]; ok {
+		return *r
+	}
+
+	r := &ConversionRecord{
+		ID:           id,
+		From:         strings.ToUpper(from),
+		To:           strings.ToUpper(to),
+		Amount:       amount,
+		ExpectedRate: expectedRate,
+		SubmittedAt:  time.Now(),
+	}
+	t.conversions[id] = r
+	t.done[id] = make(chan struct{})
+	return *r
+}
+
+//Poll calls "get_conversion_info" for every unfinished conversion,
+//a failing conversion does not stop the others and its error is returned in WorkerErrors
+func (t *ConversionTracker) Poll() error {
+	return t.PollContext(context.Background())
+}
+
+//PollContext calls "get_conversion_info" for every unfinished conversion with the context
+func (t *ConversionTracker) PollContext(ctx context.Context) error {
+	var ids []string
+	t.mu.Lock()
+	for id, r := range t.conversions {
+		if !r.Done() {
+			ids = append(ids, id)
+		}
+	}
+	t.mu.Unlock()
+
+	var errs WorkerErrors
+	for _, id := range ids {
+		info, err := t.client.GetConversionInfoContext(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("coinpayments: error polling conversion %v - %v", id, err))
+			continue
+		}
+		t.update(id, info)
+	}
+	return errs.err()
+}
+
+//Run polls on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (t *ConversionTracker) Run(ctx context.Context, interval time.Duration) error {
+	return t.client.runEvery(ctx, interval, t.PollContext, t.onError)
+}
+
+//Wait blocks until the conversion finishes or the context is done, it relies on Poll or Run being called elsewhere
+func (t *ConversionTracker) Wait(ctx context.Context, id string) (ConversionRecord, error) {
+	t.mu.Lock()
+	done, ok := t.done[id]
+	t.mu.Unlock()
+	if !ok {
+		return ConversionRecord{}, fmt.Errorf("coinpayments: unknown conversion %v", id)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ConversionRecord{}, ctx.Err()
+	case <-done:
+	}
+
+	r, _ := t.Get(id)
+	return r, nil
+}
+
+//Get returns the tracked state of a conversion
+func (t *ConversionTracker) Get(id string) (ConversionRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.conversions[id]
+	if !ok {
+		return ConversionRecord{}, false
+	}
+	return *r, true
+}
+
+//List returns every tracked conversion
+func (t *ConversionTracker) List() []ConversionRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := make([]ConversionRecord, 0, len(t.conversions))
+	for _, r := range t.conversions {
+		list = append(list, *r)
+	}
+	return list
+}
+
+func (t *ConversionTracker) update(id string, info *getConversionInfoResponse) {
+	t.mu.Lock()
+	r, ok := t.conversions[id]
+	if !ok || r.Done() {
+		t.mu.Unlock()
+		return
+	}
+
+	r.Status = info.Status
+	r.StatusText = info.StatusText
+	r.Complete = conversionComplete(info.Status)
+	r.Failed = conversionFailed(info.Status)
+	if info.Coin1 != "" {
+		r.From = strings.ToUpper(info.Coin1)
+	}
+	if info.Coin2 != "" {
+		r.To = strings.ToUpper(info.Coin2)
+	}
+	if created, err := strconv.ParseInt(info.TimeCreated, 10, 64); err == nil && created > 0 {
+		r.SubmittedAt = time.Unix(created, 0)
+	}
+
+	sent := normalizeAmount(info.AmountSentf, info.AmountSent)
+	received := normalizeAmount(info.Receivedf, info.Received)
+	if sent != nil {
+		r.AmountSent = formatAmount(sent)
+	}
+	if received != nil {
+		r.Received = formatAmount(received)
+	}
+
+	if r.Complete {
+		r.CompletedAt = time.Now()
+		if sent != nil && received != nil && sent.Sign() > 0 {
+			rate := new(big.Rat).Quo(received, sent)
+			r.Rate = formatAmount(rate)
+			if expected, err := parseAmount(r.ExpectedRate); err == nil && expected.Sign() > 0 {
+				slippage := new(big.Rat).Sub(expected, rate)
+				r.Slippage = formatAmount(slippage.Quo(slippage, expected))
+			}
+		}
+	}
+
+	result := *r
+	done := r.Done()
+	if done {
+		close(t.done[id])
+	}
+	t.mu.Unlock()
+
+	if done {
+		for _, fn := range t.events {
+			fn(result)
+		}
+	}
+}
+
+//marketRate returns how many units of to one unit of from buys at the current "rates" prices
+func (t *ConversionTracker) marketRate(ctx context.Context, from, to string) (string, error) {
+	rates, err := t.client.RatesContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	fromRate, ok := (*rates)[strings.ToUpper(from)]
+	if !ok {
+		return "", fmt.Errorf("coinpayments: no rate for %v", from)
+	}
+	toRate, ok := (*rates)[strings.ToUpper(to)]
+	if !ok {
+		return "", fmt.Errorf("coinpayments: no rate for %v", to)
+	}
+
+	fromBTC, err := parseAmount(fromRate.RateBTC)
+	if err != nil {
+		return "", err
+	}
+	toBTC, err := parseAmount(toRate.RateBTC)
+	if err != nil {
+		return "", err
+	}
+	if toBTC.Sign() == 0 {
+		return "", fmt.Errorf("coinpayments: zero rate for %v", to)
+	}
+
+	return formatAmount(new(big.Rat).Quo(fromBTC, toBTC)), nil
+}
+
+//normalizeAmount prefers the decimal form of an api amount, falling back to its integer form in 1e-8 units
+func normalizeAmount(decimal string, integer int) *big.Rat {
+	if decimal != "" {
+		if a, err := parseAmount(decimal); err == nil {
+			return a
+		}
+	}
+	if integer == 0 {
+		return nil
+	}
+	return big.NewRat(int64(integer), 100000000)
+}
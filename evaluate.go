@@ -0,0 +1,158 @@
+package coinpayments
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//PaymentOutcome classifies a received amount against the expected one
+type PaymentOutcome string
+
+//Payment outcomes
+const (
+	PaymentExact PaymentOutcome = "exact"
+	PaymentUnder PaymentOutcome = "under"
+	PaymentOver  PaymentOutcome = "over"
+	//PaymentLate means funds arrived after the transaction had already timed out
+	PaymentLate PaymentOutcome = "late"
+)
+
+//PaymentEvaluation is the result of comparing an expected and received payment amount
+type PaymentEvaluation struct {
+	Outcome  PaymentOutcome
+	Currency string
+	Expected string
+	Received string
+	//Remaining is how much more the buyer must send, set when Outcome is PaymentUnder
+	Remaining string
+	//Excess is how much the buyer sent too much, set when Outcome is PaymentOver
+	Excess   string
+	Confirms int
+}
+
+//PaymentEvaluatorOption is an option used to modify a PaymentEvaluator
+type PaymentEvaluatorOption func(evaluator *PaymentEvaluator)
+
+//PaymentEvaluator classifies payments as exact, under, over or late within per coin tolerances
+type PaymentEvaluator struct {
+	tolerances       map[string]*big.Rat
+	defaultTolerance *big.Rat
+}
+
+//NewPaymentEvaluator returns a new PaymentEvaluator with the applied options
+func NewPaymentEvaluator(options ...PaymentEvaluatorOption) *PaymentEvaluator {
+	evaluator := &PaymentEvaluator{
+		tolerances:       make(map[string]*big.Rat),
+		defaultTolerance: new(big.Rat),
+	}
+
+	for _, o := range options {
+		o(evaluator)
+	}
+	return evaluator
+}
+
+//WithPaymentTolerance is an option that treats differences up to tolerance in currency as exact payments
+func WithPaymentTolerance(currency, tolerance string) PaymentEvaluatorOption {
+	return func(evaluator *PaymentEvaluator) {
+		if t, err := parseAmount(tolerance); err == nil {
+			evaluator.tolerances[strings.ToUpper(currency)] = t
+		}
+	}
+}
+
+//WithDefaultPaymentTolerance is an option that sets the tolerance for currencies without their own
+func WithDefaultPaymentTolerance(tolerance string) PaymentEvaluatorOption {
+	return func(evaluator *PaymentEvaluator) {
+		if t, err := parseAmount(tolerance); err == nil {
+			evaluator.defaultTolerance = t
+		}
+	}
+}
+
+//Evaluate compares the received amount of currency against the expected amount
+func (e *PaymentEvaluator) Evaluate(currency, expected, received string) (*PaymentEvaluation, error) {
+	want, err := parseAmount(expected)
+	if err != nil {
+		return nil, err
+	}
+	got := new(big.Rat)
+	if received != "" {
+		if got, err = parseAmount(received); err != nil {
+			return nil, err
+		}
+	}
+
+	tolerance, ok := e.tolerances[strings.ToUpper(currency)]
+	if !ok {
+		tolerance = e.defaultTolerance
+	}
+
+	evaluation := &PaymentEvaluation{
+		Outcome:  PaymentExact,
+		Currency: currency,
+		Expected: formatAmount(want),
+		Received: formatAmount(got),
+	}
+
+	diff := new(big.Rat).Sub(got, want)
+	if new(big.Rat).Abs(diff).Cmp(tolerance) <= 0 {
+		return evaluation, nil
+	}
+
+	if diff.Sign() < 0 {
+		evaluation.Outcome = PaymentUnder
+		evaluation.Remaining = formatAmount(diff.Neg(diff))
+	} else {
+		evaluation.Outcome = PaymentOver
+		evaluation.Excess = formatAmount(diff)
+	}
+	return evaluation, nil
+}
+
+//EvaluateIPN evaluates the coin amount of a payment IPN, classifying funds on a timed out transaction as late
+func (e *PaymentEvaluator) EvaluateIPN(ipn *IPN) (*PaymentEvaluation, error) {
+	payment, ok := ipn.payment()
+	if !ok {
+		return nil, fmt.Errorf("coinpayments: IPN type '%v' is not a payment", ipn.IPNType)
+	}
+
+	status, err := strconv.Atoi(payment.Status)
+	if err != nil {
+		return nil, fmt.Errorf("coinpayments: invalid payment status %q - %v", payment.Status, err)
+	}
+
+	evaluation, err := e.Evaluate(payment.Currency2, payment.Amount2, payment.ReceivedAmount)
+	if err != nil {
+		return nil, err
+	}
+	evaluation.Confirms, _ = strconv.Atoi(payment.ReceivedConfirms)
+
+	if status < 0 && evaluation.Received != "0" {
+		markLate(evaluation)
+	}
+	return evaluation, nil
+}
+
+//EvaluateTx evaluates a "get_tx_info" response, classifying funds on a timed out transaction as late
+func (e *PaymentEvaluator) EvaluateTx(info *getTxInfoResponse) (*PaymentEvaluation, error) {
+	evaluation, err := e.Evaluate(info.Coin, info.Amountf, info.Receivedf)
+	if err != nil {
+		return nil, err
+	}
+	evaluation.Confirms = info.ReceivedConfirms
+
+	if info.Status < 0 && evaluation.Received != "0" {
+		markLate(evaluation)
+	}
+	return evaluation, nil
+}
+
+//markLate reclassifies an evaluation as late, the whole received amount is then refundable excess
+func markLate(evaluation *PaymentEvaluation) {
+	evaluation.Outcome = PaymentLate
+	evaluation.Remaining = ""
+	evaluation.Excess = evaluation.Received
+}
@@ -0,0 +1,529 @@
+package coinpayments
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ExportColumn is a column of an accounting export
+type ExportColumn string
+
+//Export columns
+const (
+	ExportTime         ExportColumn = "time"
+	ExportKind         ExportColumn = "kind"
+	ExportID           ExportColumn = "id"
+	ExportStatus       ExportColumn = "status"
+	ExportCurrency     ExportColumn = "currency"
+	ExportAmount       ExportColumn = "amount"
+	ExportFee          ExportColumn = "fee"
+	ExportNet          ExportColumn = "net"
+	ExportFiatCurrency ExportColumn = "fiat_currency"
+	ExportFiatAmount   ExportColumn = "fiat_amount"
+	ExportFiatFee      ExportColumn = "fiat_fee"
+	ExportFiatNet      ExportColumn = "fiat_net"
+	ExportAddress      ExportColumn = "address"
+	ExportSendTXID     ExportColumn = "send_txid"
+	ExportInvoice      ExportColumn = "invoice"
+	ExportCustom       ExportColumn = "custom"
+)
+
+//DefaultExportColumns are the columns written when none are configured
+var DefaultExportColumns = []ExportColumn{
+	ExportTime, ExportKind, ExportID, ExportStatus, ExportCurrency, ExportAmount, ExportFee, ExportNet,
+	ExportFiatCurrency, ExportFiatAmount, ExportFiatFee, ExportFiatNet, ExportAddress, ExportSendTXID, ExportInvoice, ExportCustom,
+}
+
+//ExportRecord is a payment, deposit or withdrawal in an accounting export, amounts are never negative
+type ExportRecord struct {
+	Time         time.Time
+	Kind         string
+	ID           string
+	Status       string
+	Currency     string
+	Amount       string
+	Fee          string
+	Net          string
+	FiatCurrency string
+	FiatAmount   string
+	FiatFee      string
+	Address      string
+	SendTXID     string
+	Invoice      string
+	Custom       string
+}
+
+//FiatConverter converts an amount between currencies at a point in time
+type FiatConverter interface {
+	ConvertFiat(amount, from, to string, at time.Time) (string, error)
+}
+
+//FiatConverterFunc adapts a function to a FiatConverter
+type FiatConverterFunc func(amount, from, to string, at time.Time) (string, error)
+
+//ConvertFiat calls f(amount, from, to, at)
+func (f FiatConverterFunc) ConvertFiat(amount, from, to string, at time.Time) (string, error) {
+	return f(amount, from, to, at)
+}
+
+//ExporterOption is an option used to modify an Exporter
+type ExporterOption func(exporter *Exporter)
+
+//Exporter builds accounting exports of payments, fees and withdrawals from IPN archives and api history
+type Exporter struct {
+	client     *Client
+	columns    []ExportColumn
+	location   *time.Location
+	timeFormat string
+	fiat       string
+	converter  FiatConverter
+}
+
+//NewExporter returns a new Exporter with the applied options
+func NewExporter(client *Client, options ...ExporterOption) *Exporter {
+	exporter := &Exporter{
+		client:     client,
+		columns:    DefaultExportColumns,
+		location:   time.UTC,
+		timeFormat: "2006-01-02 15:04:05",
+	}
+
+	for _, o := range options {
+		o(exporter)
+	}
+	return exporter
+}
+
+//WithExportColumns is an option that sets the columns and their order in CSV exports
+func WithExportColumns(columns ...ExportColumn) ExporterOption {
+	return func(exporter *Exporter) {
+		exporter.columns = columns
+	}
+}
+
+//WithExportLocation is an option that sets the time zone times are written in, UTC by default
+func WithExportLocation(location *time.Location) ExporterOption {
+	return func(exporter *Exporter) {
+		exporter.location = location
+	}
+}
+
+//WithExportTimeFormat is an option that sets the layout times are written with in CSV exports
+func WithExportTimeFormat(layout string) ExporterOption {
+	return func(exporter *Exporter) {
+		exporter.timeFormat = layout
+	}
+}
+
+//WithExportFiat is an option that reports fiat values in currency, using converter for records valued in another currency or not valued at all
+func WithExportFiat(currency string, converter FiatConverter) ExporterOption {
+	return func(exporter *Exporter) {
+		exporter.fiat = strings.ToUpper(currency)
+		exporter.converter = converter
+	}
+}
+
+//FromArchive returns a record for each completed payment, deposit and withdrawal in the archive, failing on IPNs that do not pass VerifyArchivedIPN
+func (e *Exporter) FromArchive(archive IPNArchive, filter IPNFilter) ([]ExportRecord, error) {
+	archived, err := archive.Records(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ExportRecord
+	seen := make(map[string]bool)
+	for _, a := range archived {
+		ipn, err := e.client.parseArchivedIPN(a)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error parsing archived IPN %v - %v", a.ID, err)
+		}
+
+		record, ok, err := exportIPN(ipn)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error exporting archived IPN %v - %v", a.ID, err)
+		}
+		if !ok || seen[record.Kind+":"+record.ID] {
+			continue
+		}
+		seen[record.Kind+":"+record.ID] = true
+
+		record.Time = a.ReceivedAt
+		if err := e.valueFiat(&record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+//FromHistory returns a record for each completed payment and withdrawal created between since and until,
+//the api does not report payment fees or fiat values so those are left empty unless a fiat converter is set
+func (e *Exporter) FromHistory(since, until time.Time) ([]ExportRecord, error) {
+	return e.FromHistoryContext(context.Background(), since, until)
+}
+
+//FromHistoryContext returns a record for each completed payment and withdrawal created between since and until with the context
+func (e *Exporter) FromHistoryContext(ctx context.Context, since, until time.Time) ([]ExportRecord, error) {
+	payments, err := e.client.paymentHistory(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := e.client.withdrawalHistory(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ExportRecord
+	for _, p := range payments {
+		if !paymentComplete(p.Status) {
+			continue
+		}
+		amount := p.Amount
+		if r, err := parseAmount(p.Received); err == nil && r.Sign() > 0 {
+			amount = p.Received
+		}
+		records = append(records, ExportRecord{
+			Time:     p.TimeCreated,
+			Kind:     "payment",
+			ID:       p.ID,
+			Status:   p.StatusText,
+			Currency: p.Coin,
+			Amount:   amount,
+			Address:  p.Address,
+		})
+	}
+	for _, w := range withdrawals {
+		if w.Status != 2 {
+			continue
+		}
+		records = append(records, ExportRecord{
+			Time:     w.TimeCreated,
+			Kind:     "withdrawal",
+			ID:       w.ID,
+			Status:   w.StatusText,
+			Currency: w.Coin,
+			Amount:   w.Amount,
+			Net:      w.Amount,
+			Address:  w.SendAddress,
+			SendTXID: w.SendTXID,
+		})
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	for i := range records {
+		if err := e.valueFiat(&records[i]); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+//exportIPN converts a completed payment, deposit or withdrawal IPN to a record, returning false for anything else
+func exportIPN(ipn *IPN) (ExportRecord, bool, error) {
+	switch ipn.IPNType {
+	case "deposit":
+		d := ipn.depositInformation
+		status, err := strconv.Atoi(d.Status)
+		if err != nil {
+			return ExportRecord{}, false, fmt.Errorf("coinpayments: invalid deposit status %q - %v", d.Status, err)
+		}
+		if status < 100 {
+			return ExportRecord{}, false, nil
+		}
+		net, err := subtractAmount(d.Amount, d.Fee)
+		if err != nil {
+			return ExportRecord{}, false, err
+		}
+		return ExportRecord{
+			Kind:         "deposit",
+			ID:           d.TransactionID,
+			Status:       d.StatusText,
+			Currency:     d.Currency,
+			Amount:       d.Amount,
+			Fee:          d.Fee,
+			Net:          net,
+			FiatCurrency: d.FiatCoin,
+			FiatAmount:   d.FiatAmount,
+			FiatFee:      d.FiatFee,
+			Address:      d.Address,
+		}, true, nil
+	case "withdrawal":
+		w := ipn.withdrawalInformation
+		status, err := strconv.Atoi(w.Status)
+		if err != nil {
+			return ExportRecord{}, false, fmt.Errorf("coinpayments: invalid withdrawal status %q - %v", w.Status, err)
+		}
+		if status != 2 {
+			return ExportRecord{}, false, nil
+		}
+		return ExportRecord{
+			Kind:     "withdrawal",
+			ID:       w.ID,
+			Status:   w.StatusText,
+			Currency: w.Currency,
+			Amount:   w.Amount,
+			Net:      w.Amount,
+			Address:  w.Address,
+			SendTXID: w.TransactionID,
+		}, true, nil
+	}
+
+	p, ok := ipn.payment()
+	if !ok {
+		return ExportRecord{}, false, nil
+	}
+	status, err := strconv.Atoi(p.Status)
+	if err != nil {
+		return ExportRecord{}, false, fmt.Errorf("coinpayments: invalid payment status %q - %v", p.Status, err)
+	}
+	if !paymentComplete(status) {
+		return ExportRecord{}, false, nil
+	}
+
+	amount := p.ReceivedAmount
+	if amount == "" {
+		amount = p.Amount2
+	}
+	net, err := subtractAmount(amount, p.Fee)
+	if err != nil {
+		return ExportRecord{}, false, err
+	}
+	record := ExportRecord{
+		Kind:         "payment",
+		ID:           p.TransactionID,
+		Status:       p.StatusText,
+		Currency:     p.Currency2,
+		Amount:       amount,
+		Fee:          p.Fee,
+		Net:          net,
+		FiatCurrency: p.Currency1,
+		FiatAmount:   p.Amount1,
+		Invoice:      p.Invoice,
+		Custom:       p.Custom,
+	}
+
+	//the fee is charged in currency2, value it at the price the buyer was quoted
+	if p.Fee != "" {
+		fee, err1 := parseAmount(p.Fee)
+		price, err2 := parseAmount(p.Amount1)
+		quoted, err3 := parseAmount(p.Amount2)
+		if err1 == nil && err2 == nil && err3 == nil && quoted.Sign() != 0 {
+			fiatFee := new(big.Rat).Mul(fee, price)
+			record.FiatFee = formatAmount(fiatFee.Quo(fiatFee, quoted))
+		}
+	}
+	return record, true, nil
+}
+
+//valueFiat puts the record's fiat values in the configured fiat currency, if any
+func (e *Exporter) valueFiat(record *ExportRecord) error {
+	if e.fiat == "" || strings.EqualFold(record.FiatCurrency, e.fiat) {
+		return nil
+	}
+
+	amount, fee, from := record.FiatAmount, record.FiatFee, record.FiatCurrency
+	if from == "" {
+		amount, fee, from = record.Amount, record.Fee, record.Currency
+	}
+	if e.converter == nil {
+		return fmt.Errorf("coinpayments: no fiat converter to value %v %v in %v", record.Kind, record.ID, e.fiat)
+	}
+
+	converted, err := e.converter.ConvertFiat(amount, from, e.fiat, record.Time)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error converting %v %v to %v - %v", record.Kind, record.ID, e.fiat, err)
+	}
+	record.FiatAmount = converted
+
+	record.FiatFee = ""
+	if fee != "" {
+		if record.FiatFee, err = e.converter.ConvertFiat(fee, from, e.fiat, record.Time); err != nil {
+			return fmt.Errorf("coinpayments: error converting %v %v to %v - %v", record.Kind, record.ID, e.fiat, err)
+		}
+	}
+	record.FiatCurrency = e.fiat
+	return nil
+}
+
+//column returns the value of a column for the record
+func (e *Exporter) column(record ExportRecord, column ExportColumn) string {
+	switch column {
+	case ExportTime:
+		return record.Time.In(e.location).Format(e.timeFormat)
+	case ExportKind:
+		return record.Kind
+	case ExportID:
+		return record.ID
+	case ExportStatus:
+		return record.Status
+	case ExportCurrency:
+		return record.Currency
+	case ExportAmount:
+		return record.Amount
+	case ExportFee:
+		return record.Fee
+	case ExportNet:
+		return record.Net
+	case ExportFiatCurrency:
+		return record.FiatCurrency
+	case ExportFiatAmount:
+		return record.FiatAmount
+	case ExportFiatFee:
+		return record.FiatFee
+	case ExportFiatNet:
+		if record.FiatFee == "" {
+			return record.FiatAmount
+		}
+		net, err := subtractAmount(record.FiatAmount, record.FiatFee)
+		if err != nil {
+			return ""
+		}
+		return net
+	case ExportAddress:
+		return record.Address
+	case ExportSendTXID:
+		return record.SendTXID
+	case ExportInvoice:
+		return record.Invoice
+	case ExportCustom:
+		return record.Custom
+	}
+	return ""
+}
+
+//WriteCSV writes the records as CSV with a header row of the configured columns
+func (e *Exporter) WriteCSV(w io.Writer, records []ExportRecord) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		header[i] = string(c)
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("coinpayments: error writing export - %v", err)
+	}
+
+	row := make([]string, len(e.columns))
+	for _, record := range records {
+		for i, c := range e.columns {
+			row[i] = e.column(record, c)
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("coinpayments: error writing export - %v", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("coinpayments: error writing export - %v", err)
+	}
+	return nil
+}
+
+//WriteOFX writes the records as an OFX 1.02 statement per currency, with fees as separate transactions
+func (e *Exporter) WriteOFX(w io.Writer, records []ExportRecord) error {
+	byCurrency := make(map[string][]ExportRecord)
+	var currencies []string
+	for _, r := range records {
+		coin := strings.ToUpper(r.Currency)
+		if _, ok := byCurrency[coin]; !ok {
+			currencies = append(currencies, coin)
+		}
+		byCurrency[coin] = append(byCurrency[coin], r)
+	}
+	sort.Strings(currencies)
+
+	var b strings.Builder
+	b.WriteString("OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nSECURITY:NONE\nENCODING:USASCII\nCHARSET:1252\nCOMPRESSION:NONE\nOLDFILEUID:NONE\nNEWFILEUID:NONE\n\n")
+	b.WriteString("<OFX>\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS>")
+	fmt.Fprintf(&b, "<DTSERVER>%v<LANGUAGE>ENG</SONRS></SIGNONMSGSRSV1>\n<BANKMSGSRSV1>\n", e.ofxTime(time.Now()))
+
+	for n, coin := range currencies {
+		list := byCurrency[coin]
+		start, end := list[0].Time, list[0].Time
+		for _, r := range list {
+			if r.Time.Before(start) {
+				start = r.Time
+			}
+			if r.Time.After(end) {
+				end = r.Time
+			}
+		}
+
+		fmt.Fprintf(&b, "<STMTTRNRS><TRNUID>%d<STATUS><CODE>0<SEVERITY>INFO</STATUS><STMTRS><CURDEF>%v\n", n+1, ofxEscape(coin))
+		fmt.Fprintf(&b, "<BANKACCTFROM><BANKID>COINPAYMENTS<ACCTID>%v<ACCTTYPE>CHECKING</BANKACCTFROM>\n", ofxEscape(coin))
+		fmt.Fprintf(&b, "<BANKTRANLIST><DTSTART>%v<DTEND>%v\n", e.ofxTime(start), e.ofxTime(end))
+		for _, r := range list {
+			trnType, amount := "CREDIT", r.Amount
+			if r.Kind == "withdrawal" {
+				trnType, amount = "DEBIT", negate(r.Amount)
+			}
+			e.writeOFXTransaction(&b, trnType, r.Time, amount, r.ID, r.Kind, r.FiatAmount, r)
+
+			if fee, err := parseAmount(r.Fee); err == nil && fee.Sign() != 0 {
+				e.writeOFXTransaction(&b, "FEE", r.Time, formatAmount(fee.Neg(fee)), r.ID+"-fee", r.Kind+" fee", r.FiatFee, r)
+			}
+		}
+		b.WriteString("</BANKTRANLIST></STMTRS></STMTTRNRS>\n")
+	}
+	b.WriteString("</BANKMSGSRSV1>\n</OFX>\n")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("coinpayments: error writing export - %v", err)
+	}
+	return nil
+}
+
+func (e *Exporter) writeOFXTransaction(b *strings.Builder, trnType string, t time.Time, amount, id, name, fiat string, r ExportRecord) {
+	var memo []string
+	if fiat != "" {
+		memo = append(memo, fiat+" "+r.FiatCurrency)
+	}
+	if r.SendTXID != "" {
+		memo = append(memo, "txid "+r.SendTXID)
+	}
+	if r.Invoice != "" {
+		memo = append(memo, "invoice "+r.Invoice)
+	}
+
+	fmt.Fprintf(b, "<STMTTRN><TRNTYPE>%v<DTPOSTED>%v<TRNAMT>%v<FITID>%v<NAME>%v", trnType, e.ofxTime(t), amount, ofxEscape(id), ofxEscape(name))
+	if len(memo) > 0 {
+		fmt.Fprintf(b, "<MEMO>%v", ofxEscape(strings.Join(memo, ", ")))
+	}
+	b.WriteString("</STMTTRN>\n")
+}
+
+//ofxTime formats a time as an OFX date in the configured time zone
+func (e *Exporter) ofxTime(t time.Time) string {
+	t = t.In(e.location)
+	_, offset := t.Zone()
+	return fmt.Sprintf("%v[%+g:%v]", t.Format("20060102150405"), float64(offset)/3600, t.Format("MST"))
+}
+
+//ofxEscape escapes the characters SGML treats as markup
+func ofxEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+//subtractAmount returns a - b as decimal strings, treating an empty b as zero
+func subtractAmount(a, b string) (string, error) {
+	x, err := parseAmount(a)
+	if err != nil {
+		return "", err
+	}
+	if b == "" {
+		return formatAmount(x), nil
+	}
+	y, err := parseAmount(b)
+	if err != nil {
+		return "", err
+	}
+	return formatAmount(x.Sub(x, y)), nil
+}
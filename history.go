@@ -0,0 +1,136 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//historyPageSize is how many ids or withdrawals are fetched per history call
+const historyPageSize = 100
+
+//historyMultiSize is the most transactions a "get_tx_info_multi" call accepts
+const historyMultiSize = 25
+
+//historyPayment is a transaction from "get_tx_info_multi"
+type historyPayment struct {
+	ID          string
+	TimeCreated time.Time
+	Status      int
+	StatusText  string
+	Coin        string
+	Amount      string
+	Received    string
+	Address     string
+}
+
+//historyWithdrawal is a withdrawal from "get_withdrawal_history"
+type historyWithdrawal struct {
+	ID          string
+	TimeCreated time.Time
+	Status      int
+	StatusText  string
+	Coin        string
+	Amount      string
+	Note        string
+	SendAddress string
+	SendDestTag string
+	SendTXID    string
+}
+
+//paymentHistory walks "get_tx_ids" and "get_tx_info_multi" with the context for transactions created between since and until
+func (c *Client) paymentHistory(ctx context.Context, since, until time.Time) ([]historyPayment, error) {
+	var ids []string
+	for start := 0; ; start += historyPageSize {
+		page, err := c.GetTxIdsContext(ctx,
+			WithOptionalValue("limit", strconv.Itoa(historyPageSize)),
+			WithOptionalValue("start", strconv.Itoa(start)),
+			WithOptionalValue("newer", strconv.FormatInt(since.Unix(), 10)),
+			WithOptionalValue("all", "1"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, *page...)
+		if len(*page) < historyPageSize {
+			break
+		}
+	}
+
+	var payments []historyPayment
+	for i := 0; i < len(ids); i += historyMultiSize {
+		end := i + historyMultiSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		infos, err := c.GetTxInfoMultiContext(ctx, strings.Join(ids[i:end], "|"))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids[i:end] {
+			info, ok := (*infos)[id]
+			if !ok {
+				continue
+			}
+			if info.Error != "" && info.Error != apiSuccess {
+				return nil, fmt.Errorf("coinpayments: api error for transaction %v - %v", id, info.Error)
+			}
+			created := time.Unix(int64(info.TimeCreated), 0)
+			if created.Before(since) || !created.Before(until) {
+				continue
+			}
+			payments = append(payments, historyPayment{
+				ID:          id,
+				TimeCreated: created,
+				Status:      info.Status,
+				StatusText:  info.StatusText,
+				Coin:        info.Coin,
+				Amount:      info.Amountf,
+				Received:    info.Recievedf,
+				Address:     info.PaymentAddress,
+			})
+		}
+	}
+	return payments, nil
+}
+
+//withdrawalHistory walks "get_withdrawal_history" with the context for withdrawals created between since and until
+func (c *Client) withdrawalHistory(ctx context.Context, since, until time.Time) ([]historyWithdrawal, error) {
+	var withdrawals []historyWithdrawal
+	for start := 0; ; start += historyPageSize {
+		page, err := c.GetWithdrawalHistoryContext(ctx,
+			WithOptionalValue("limit", strconv.Itoa(historyPageSize)),
+			WithOptionalValue("start", strconv.Itoa(start)),
+			WithOptionalValue("newer", strconv.FormatInt(since.Unix(), 10)),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, w := range *page {
+			created := time.Unix(int64(w.TimeCreated), 0)
+			if created.Before(since) || !created.Before(until) {
+				continue
+			}
+			withdrawals = append(withdrawals, historyWithdrawal{
+				ID:          w.ID,
+				TimeCreated: created,
+				Status:      w.Status,
+				StatusText:  w.StatusText,
+				Coin:        w.Coin,
+				Amount:      w.Amountf,
+				Note:        w.Note,
+				SendAddress: w.SendAddress,
+				SendDestTag: w.SendDestTag,
+				SendTXID:    w.SendTXID,
+			})
+		}
+		if len(*page) < historyPageSize {
+			break
+		}
+	}
+	return withdrawals, nil
+}
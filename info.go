@@ -1,6 +1,9 @@
 package coinpayments
 
-import "net/url"
+import (
+	"context"
+	"net/url"
+)
 
 //getBasicInfoResponse is the api response of a "get_basic_info" call
 type getBasicInfoResponse struct {
@@ -12,6 +15,11 @@ type getBasicInfoResponse struct {
 
 //GetBasicInfo calls the "get_basic_info" command
 func (c *Client) GetBasicInfo(optionals ...OptionalValue) (*getBasicInfoResponse, error) {
+	return c.GetBasicInfoContext(context.Background(), optionals...)
+}
+
+//GetBasicInfoContext calls the "get_basic_info" command with the context
+func (c *Client) GetBasicInfoContext(ctx context.Context, optionals ...OptionalValue) (*getBasicInfoResponse, error) {
 	values := &url.Values{}
 	addOptionals(optionals, values)
 
@@ -19,7 +27,7 @@ func (c *Client) GetBasicInfo(optionals ...OptionalValue) (*getBasicInfoResponse
 		errResponse
 		Result *getBasicInfoResponse `json:"result"`
 	}
-	if err := c.call("get_basic_info", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_basic_info", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -41,6 +49,11 @@ type ratesResponse map[string]struct {
 
 //Rates calls the "rates" command
 func (c *Client) Rates(optionals ...OptionalValue) (*ratesResponse, error) {
+	return c.RatesContext(context.Background(), optionals...)
+}
+
+//RatesContext calls the "rates" command with the context
+func (c *Client) RatesContext(ctx context.Context, optionals ...OptionalValue) (*ratesResponse, error) {
 	values := &url.Values{}
 	addOptionals(optionals, values)
 
@@ -48,7 +61,7 @@ func (c *Client) Rates(optionals ...OptionalValue) (*ratesResponse, error) {
 		errResponse
 		Result *ratesResponse `json:"result"`
 	}
-	if err := c.call("rates", values, &resp); err != nil {
+	if err := c.callContext(ctx, "rates", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,556 @@
+package coinpayments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//ErrInvoiceNotFound is returned by an InvoiceStore when no invoice has the requested id
+var ErrInvoiceNotFound = errors.New("coinpayments: invoice not found")
+
+//InvoiceStatus is the rolled up payment state of an invoice
+type InvoiceStatus string
+
+//Invoice statuses
+const (
+	InvoiceOpen          InvoiceStatus = "open"
+	InvoicePartiallyPaid InvoiceStatus = "partially_paid"
+	InvoicePaid          InvoiceStatus = "paid"
+	InvoiceOverpaid      InvoiceStatus = "overpaid"
+	InvoiceExpired       InvoiceStatus = "expired"
+)
+
+//Invoice is a merchant invoice that may be paid through several coinpayments transactions
+type Invoice struct {
+	ID         string
+	Amount     string
+	Currency   string
+	BuyerEmail string
+	Status     InvoiceStatus
+	//Paid is the value of completed attempts, in Currency
+	Paid string
+	//Pending is the value received by attempts that have not completed yet, in Currency
+	Pending   string
+	Attempts  []InvoiceAttempt
+	CreatedAt time.Time
+	//ExpiresAt is when the invoice stops accepting new attempts, or zero for never
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+//InvoiceAttempt is a single "create_transaction" made for an invoice
+type InvoiceAttempt struct {
+	TxnID       string
+	Amount1     string
+	Currency2   string
+	Amount2     string
+	Received    string
+	Status      int
+	StatusText  string
+	Address     string
+	CheckoutURL string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
+//pending reports whether the attempt can still receive funds
+func (a InvoiceAttempt) pending() bool {
+	return !paymentComplete(a.Status) && a.Status >= 0
+}
+
+//InvoiceStore persists invoices for an InvoiceManager
+type InvoiceStore interface {
+	SaveInvoice(invoice *Invoice) error
+	//LoadInvoice returns ErrInvoiceNotFound if no invoice has the id
+	LoadInvoice(id string) (*Invoice, error)
+	ListInvoices() ([]*Invoice, error)
+}
+
+//MemoryInvoiceStore is an InvoiceStore that keeps invoices in memory
+type MemoryInvoiceStore struct {
+	mu       sync.Mutex
+	invoices map[string]Invoice
+}
+
+//NewMemoryInvoiceStore returns an empty MemoryInvoiceStore
+func NewMemoryInvoiceStore() *MemoryInvoiceStore {
+	return &MemoryInvoiceStore{invoices: make(map[string]Invoice)}
+}
+
+//SaveInvoice stores a copy of the invoice
+func (s *MemoryInvoiceStore) SaveInvoice(invoice *Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *invoice
+	c.Attempts = append([]InvoiceAttempt(nil), invoice.Attempts...)
+	s.invoices[invoice.ID] = c
+	return nil
+}
+
+//LoadInvoice returns a copy of the stored invoice
+func (s *MemoryInvoiceStore) LoadInvoice(id string) (*Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invoice, ok := s.invoices[id]
+	if !ok {
+		return nil, ErrInvoiceNotFound
+	}
+	invoice.Attempts = append([]InvoiceAttempt(nil), invoice.Attempts...)
+	return &invoice, nil
+}
+
+//ListInvoices returns copies of every stored invoice
+func (s *MemoryInvoiceStore) ListInvoices() ([]*Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Invoice, 0, len(s.invoices))
+	for _, invoice := range s.invoices {
+		invoice := invoice
+		invoice.Attempts = append([]InvoiceAttempt(nil), invoice.Attempts...)
+		list = append(list, &invoice)
+	}
+	return list, nil
+}
+
+//InvoiceManagerOption is an option used to modify an InvoiceManager
+type InvoiceManagerOption func(manager *InvoiceManager)
+
+//InvoiceManager creates transactions for invoices and rolls their state up from IPNs and "get_tx_info"
+type InvoiceManager struct {
+	client   *Client
+	store    InvoiceStore
+	idField  string
+	onChange []func(invoice Invoice)
+	onError  ErrorFunc
+
+	mu sync.Mutex
+}
+
+//NewInvoiceManager returns a new InvoiceManager persisting to store with the applied options
+func NewInvoiceManager(client *Client, store InvoiceStore, options ...InvoiceManagerOption) *InvoiceManager {
+	manager := &InvoiceManager{
+		client:  client,
+		store:   store,
+		idField: "invoice",
+	}
+
+	for _, o := range options {
+		o(manager)
+	}
+	return manager
+}
+
+//WithInvoiceIDField is an option that carries invoice ids in the "custom" field instead of "invoice"
+func WithInvoiceIDField(field string) InvoiceManagerOption {
+	return func(manager *InvoiceManager) {
+		manager.idField = field
+	}
+}
+
+//WithInvoiceChangeFunc is an option that registers a callback for invoice status changes
+func WithInvoiceChangeFunc(fn func(invoice Invoice)) InvoiceManagerOption {
+	return func(manager *InvoiceManager) {
+		manager.onChange = append(manager.onChange, fn)
+	}
+}
+
+//WithInvoiceErrorFunc is an option that receives the errors Run handles without stopping
+func WithInvoiceErrorFunc(fn ErrorFunc) InvoiceManagerOption {
+	return func(manager *InvoiceManager) {
+		manager.onError = fn
+	}
+}
+
+//Create stores a new open invoice for amount of currency, ttl of zero never expires
+func (m *InvoiceManager) Create(id, amount, currency, buyerEmail string, ttl time.Duration) (*Invoice, error) {
+	if _, err := parseAmount(amount); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.store.LoadInvoice(id); err == nil {
+		return nil, fmt.Errorf("coinpayments: invoice %v already exists", id)
+	} else if err != ErrInvoiceNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	invoice := &Invoice{
+		ID:         id,
+		Amount:     amount,
+		Currency:   currency,
+		BuyerEmail: buyerEmail,
+		Status:     InvoiceOpen,
+		Paid:       "0",
+		Pending:    "0",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if ttl > 0 {
+		invoice.ExpiresAt = now.Add(ttl)
+	}
+
+	if err := m.store.SaveInvoice(invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+//Get returns the stored invoice
+func (m *InvoiceManager) Get(id string) (*Invoice, error) {
+	return m.store.LoadInvoice(id)
+}
+
+//Pay creates a transaction for the unpaid remainder of an invoice in currency2, also after earlier attempts expired
+func (m *InvoiceManager) Pay(id, currency2 string, optionals ...OptionalValue) (*InvoiceAttempt, error) {
+	return m.PayContext(context.Background(), id, currency2, optionals...)
+}
+
+//PayContext creates a transaction for the unpaid remainder of an invoice in currency2 with the context
+func (m *InvoiceManager) PayContext(ctx context.Context, id, currency2 string, optionals ...OptionalValue) (*InvoiceAttempt, error) {
+	m.mu.Lock()
+	invoice, err := m.store.LoadInvoice(id)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	remaining, err := payableRemaining(invoice, time.Now())
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	//the api call is made without holding m.mu so IPNs and refreshes of other invoices are not held up
+	optionals = append(optionals, WithOptionalValue(m.idField, id))
+	resp, err := m.client.CreateTransactionContext(ctx, formatAmount(remaining), invoice.Currency, currency2, invoice.BuyerEmail, optionals...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invoice, err = m.store.LoadInvoice(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	attempt := InvoiceAttempt{
+		TxnID:       resp.TxnId,
+		Amount1:     formatAmount(remaining),
+		Currency2:   currency2,
+		Amount2:     resp.Amount,
+		Received:    "0",
+		Address:     resp.Address,
+		CheckoutURL: resp.CheckoutURL,
+		ExpiresAt:   now.Add(time.Duration(resp.Timeout) * time.Second),
+		CreatedAt:   now,
+	}
+	//an IPN for the new transaction may have arrived while the api call was in flight
+	if existing := findAttempt(invoice, resp.TxnId); existing != nil {
+		existing.Amount1 = attempt.Amount1
+		existing.Currency2 = attempt.Currency2
+		existing.Address = attempt.Address
+		existing.CheckoutURL = attempt.CheckoutURL
+		existing.ExpiresAt = attempt.ExpiresAt
+		if existing.Received == "" {
+			existing.Received = "0"
+		}
+		attempt = *existing
+	} else {
+		invoice.Attempts = append(invoice.Attempts, attempt)
+	}
+
+	if err := m.save(invoice); err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+//payableRemaining returns the unpaid remainder of an invoice that can still be paid
+func payableRemaining(invoice *Invoice, now time.Time) (*big.Rat, error) {
+	switch invoice.Status {
+	case InvoicePaid, InvoiceOverpaid:
+		return nil, fmt.Errorf("coinpayments: invoice %v is %v", invoice.ID, invoice.Status)
+	}
+	if !invoice.ExpiresAt.IsZero() && now.After(invoice.ExpiresAt) {
+		return nil, fmt.Errorf("coinpayments: invoice %v has expired", invoice.ID)
+	}
+	return invoiceRemaining(invoice)
+}
+
+//HandleIPN updates the invoice a payment IPN belongs to, ignoring IPNs for unknown invoices
+func (m *InvoiceManager) HandleIPN(ipn *IPN) error {
+	payment, ok := ipn.payment()
+	if !ok {
+		return nil
+	}
+
+	id := payment.Invoice
+	if m.idField == "custom" {
+		id = payment.Custom
+	}
+	if id == "" {
+		return nil
+	}
+
+	status, err := strconv.Atoi(payment.Status)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid payment status %q - %v", payment.Status, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invoice, err := m.store.LoadInvoice(id)
+	if err == ErrInvoiceNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	attempt := findAttempt(invoice, payment.TransactionID)
+	if attempt == nil {
+		invoice.Attempts = append(invoice.Attempts, InvoiceAttempt{
+			TxnID:     payment.TransactionID,
+			Amount1:   payment.Amount1,
+			Currency2: payment.Currency2,
+			Amount2:   payment.Amount2,
+			CreatedAt: time.Now(),
+		})
+		attempt = &invoice.Attempts[len(invoice.Attempts)-1]
+	}
+
+	attempt.Status = status
+	attempt.StatusText = payment.StatusText
+	if payment.ReceivedAmount != "" {
+		attempt.Received = payment.ReceivedAmount
+	}
+	return m.save(invoice)
+}
+
+//Refresh updates every pending attempt of an invoice with "get_tx_info"
+func (m *InvoiceManager) Refresh(id string) (*Invoice, error) {
+	return m.RefreshContext(context.Background(), id)
+}
+
+//RefreshContext updates every pending attempt of an invoice with "get_tx_info" with the context
+func (m *InvoiceManager) RefreshContext(ctx context.Context, id string) (*Invoice, error) {
+	m.mu.Lock()
+	invoice, err := m.store.LoadInvoice(id)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	//the api calls are made without holding m.mu, the results are applied to the invoice as it is stored afterwards
+	infos := make(map[string]*getTxInfoResponse)
+	for _, attempt := range invoice.Attempts {
+		if !attempt.pending() {
+			continue
+		}
+
+		info, err := m.client.GetTxInfoContext(ctx, attempt.TxnID)
+		if err != nil {
+			return nil, err
+		}
+		infos[attempt.TxnID] = info
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invoice, err = m.store.LoadInvoice(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range invoice.Attempts {
+		attempt := &invoice.Attempts[i]
+		info, ok := infos[attempt.TxnID]
+		if !ok || !attempt.pending() {
+			continue
+		}
+
+		attempt.Status = info.Status
+		attempt.StatusText = info.StatusText
+		attempt.Received = info.Receivedf
+		if info.Amountf != "" {
+			attempt.Amount2 = info.Amountf
+		}
+	}
+
+	if err := m.save(invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+//RefreshOpen refreshes every invoice that is not yet paid or expired,
+//a failing invoice does not stop the others and its error is returned in WorkerErrors
+func (m *InvoiceManager) RefreshOpen() error {
+	return m.RefreshOpenContext(context.Background())
+}
+
+//RefreshOpenContext refreshes every invoice that is not yet paid or expired with the context
+func (m *InvoiceManager) RefreshOpenContext(ctx context.Context) error {
+	invoices, err := m.store.ListInvoices()
+	if err != nil {
+		return err
+	}
+
+	var errs WorkerErrors
+	for _, invoice := range invoices {
+		switch invoice.Status {
+		case InvoiceOpen, InvoicePartiallyPaid:
+			if _, err := m.RefreshContext(ctx, invoice.ID); err != nil {
+				errs = append(errs, fmt.Errorf("coinpayments: error refreshing invoice %v - %v", invoice.ID, err))
+			}
+		}
+	}
+	return errs.err()
+}
+
+//Run refreshes open invoices on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (m *InvoiceManager) Run(ctx context.Context, interval time.Duration) error {
+	return m.client.runEvery(ctx, interval, m.RefreshOpenContext, m.onError)
+}
+
+//save rolls up the invoice status and stores it, the caller must hold m.mu
+func (m *InvoiceManager) save(invoice *Invoice) error {
+	previous := invoice.Status
+	if err := rollupInvoice(invoice, time.Now()); err != nil {
+		return err
+	}
+	invoice.UpdatedAt = time.Now()
+
+	if err := m.store.SaveInvoice(invoice); err != nil {
+		return err
+	}
+
+	if invoice.Status != previous {
+		for _, fn := range m.onChange {
+			fn(*invoice)
+		}
+	}
+	return nil
+}
+
+func findAttempt(invoice *Invoice, txnID string) *InvoiceAttempt {
+	for i := range invoice.Attempts {
+		if invoice.Attempts[i].TxnID == txnID {
+			return &invoice.Attempts[i]
+		}
+	}
+	return nil
+}
+
+//invoicePaid returns the value received by completed attempts and by attempts still confirming, in the invoice currency
+func invoicePaid(invoice *Invoice) (*big.Rat, *big.Rat, error) {
+	paid, pending := new(big.Rat), new(big.Rat)
+	for _, a := range invoice.Attempts {
+		if a.Received == "" || a.Amount2 == "" || a.Amount1 == "" || a.Status < 0 {
+			continue
+		}
+
+		received, err := parseAmount(a.Received)
+		if err != nil {
+			return nil, nil, err
+		}
+		if received.Sign() == 0 {
+			continue
+		}
+		amount1, err := parseAmount(a.Amount1)
+		if err != nil {
+			return nil, nil, err
+		}
+		amount2, err := parseAmount(a.Amount2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if amount2.Sign() == 0 {
+			continue
+		}
+
+		value := new(big.Rat).Quo(received, amount2)
+		value.Mul(value, amount1)
+		if paymentComplete(a.Status) {
+			paid.Add(paid, value)
+		} else {
+			pending.Add(pending, value)
+		}
+	}
+	return paid, pending, nil
+}
+
+func invoiceRemaining(invoice *Invoice) (*big.Rat, error) {
+	amount, err := parseAmount(invoice.Amount)
+	if err != nil {
+		return nil, err
+	}
+	paid, pending, err := invoicePaid(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	//funds still confirming are on their way, so they are not asked for again
+	remaining := new(big.Rat).Sub(amount, paid)
+	remaining.Sub(remaining, pending)
+	if remaining.Sign() <= 0 {
+		return nil, fmt.Errorf("coinpayments: invoice %v has nothing left to pay", invoice.ID)
+	}
+	return remaining, nil
+}
+
+//rollupInvoice derives the invoice status from its attempts
+func rollupInvoice(invoice *Invoice, now time.Time) error {
+	amount, err := parseAmount(invoice.Amount)
+	if err != nil {
+		return err
+	}
+	paid, received, err := invoicePaid(invoice)
+	if err != nil {
+		return err
+	}
+	invoice.Paid = formatAmount(paid)
+	invoice.Pending = formatAmount(received)
+
+	pending := false
+	for _, a := range invoice.Attempts {
+		if a.pending() && (a.ExpiresAt.IsZero() || now.Before(a.ExpiresAt)) {
+			pending = true
+		}
+	}
+
+	//values within the api's precision of the invoice amount count as paid exactly
+	tolerance := big.NewRat(1, 100000000)
+	diff := new(big.Rat).Sub(paid, amount)
+	expired := !invoice.ExpiresAt.IsZero() && now.After(invoice.ExpiresAt)
+
+	switch {
+	case new(big.Rat).Abs(diff).Cmp(tolerance) <= 0:
+		invoice.Status = InvoicePaid
+	case diff.Sign() > 0:
+		invoice.Status = InvoiceOverpaid
+	case paid.Sign() > 0:
+		invoice.Status = InvoicePartiallyPaid
+	case pending:
+		invoice.Status = InvoiceOpen
+	case expired:
+		invoice.Status = InvoiceExpired
+	default:
+		invoice.Status = InvoiceOpen
+	}
+	return nil
+}
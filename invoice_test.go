@@ -0,0 +1,199 @@
+package coinpayments
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMemoryInvoiceStoreListInvoices(t *testing.T) {
+	store := NewMemoryInvoiceStore()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.SaveInvoice(&Invoice{ID: id, Amount: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invoices, err := store.ListInvoices()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, invoice := range invoices {
+		ids = append(ids, invoice.ID)
+	}
+	sort.Strings(ids)
+	if len(ids) != 3 || ids[0] != "a" || ids[1] != "b" || ids[2] != "c" {
+		t.Errorf("ListInvoices returned ids %v, want [a b c]", ids)
+	}
+}
+
+func TestRollupInvoice(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		attempts  []InvoiceAttempt
+		expiresAt time.Time
+		status    InvoiceStatus
+		paid      string
+		pending   string
+	}{
+		{
+			name:     "confirming",
+			attempts: []InvoiceAttempt{{Amount1: "10", Amount2: "0.5", Received: "0.5", Status: 1, ExpiresAt: now.Add(time.Hour)}},
+			status:   InvoiceOpen,
+			paid:     "0",
+			pending:  "10",
+		},
+		{
+			name:     "complete",
+			attempts: []InvoiceAttempt{{Amount1: "10", Amount2: "0.5", Received: "0.5", Status: 100}},
+			status:   InvoicePaid,
+			paid:     "10",
+			pending:  "0",
+		},
+		{
+			name:     "cancelled without a ttl",
+			attempts: []InvoiceAttempt{{Amount1: "10", Amount2: "0.5", Received: "0.5", Status: -1}},
+			status:   InvoiceOpen,
+			paid:     "0",
+			pending:  "0",
+		},
+		{
+			name:     "lapsed without a ttl",
+			attempts: []InvoiceAttempt{{Amount1: "10", Amount2: "0.5", Received: "0", Status: 0, ExpiresAt: now.Add(-time.Hour)}},
+			status:   InvoiceOpen,
+			paid:     "0",
+			pending:  "0",
+		},
+		{
+			name:      "cancelled after the ttl",
+			attempts:  []InvoiceAttempt{{Amount1: "10", Amount2: "0.5", Received: "0.5", Status: -1}},
+			expiresAt: now.Add(-time.Minute),
+			status:    InvoiceExpired,
+			paid:      "0",
+			pending:   "0",
+		},
+		{
+			name:      "unpaid after the ttl",
+			expiresAt: now.Add(-time.Minute),
+			status:    InvoiceExpired,
+			paid:      "0",
+			pending:   "0",
+		},
+		{
+			name: "partially complete",
+			attempts: []InvoiceAttempt{
+				{Amount1: "10", Amount2: "0.5", Received: "0.25", Status: 100},
+				{Amount1: "5", Amount2: "0.25", Received: "0.25", Status: 0, ExpiresAt: now.Add(time.Hour)},
+			},
+			status:  InvoicePartiallyPaid,
+			paid:    "5",
+			pending: "5",
+		},
+		{
+			name:     "overpaid",
+			attempts: []InvoiceAttempt{{Amount1: "10", Amount2: "0.5", Received: "0.6", Status: 2}},
+			status:   InvoiceOverpaid,
+			paid:     "12",
+			pending:  "0",
+		},
+	}
+
+	for _, tt := range tests {
+		invoice := &Invoice{ID: "inv", Amount: "10", Attempts: tt.attempts, ExpiresAt: tt.expiresAt}
+		if err := rollupInvoice(invoice, now); err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if invoice.Status != tt.status || invoice.Paid != tt.paid || invoice.Pending != tt.pending {
+			t.Errorf("%v: got status %v paid %v pending %v, want %v %v %v", tt.name, invoice.Status, invoice.Paid, invoice.Pending, tt.status, tt.paid, tt.pending)
+		}
+	}
+}
+
+func TestInvoicePayAndRefresh(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_transaction", `{"amount":"0.5","txn_id":"T1","address":"addr","timeout":3600}`)
+	manager := NewInvoiceManager(api.client(), NewMemoryInvoiceStore())
+
+	if _, err := manager.Create("inv", "10", "USD", "buyer@example.com", 0); err != nil {
+		t.Fatal(err)
+	}
+	attempt, err := manager.PayContext(context.Background(), "inv", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.TxnID != "T1" || attempt.Amount1 != "10" || api.calls[0].Get("invoice") != "inv" {
+		t.Fatalf("PayContext returned %+v with values %v, want T1 for 10 tagged with the invoice", attempt, api.calls[0])
+	}
+
+	api.set("get_tx_info", `{"status":1,"status_text":"confirming","amountf":"0.5","receivedf":"0.25"}`)
+	invoice, err := manager.Refresh("inv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Status != InvoiceOpen || invoice.Pending != "5" {
+		t.Errorf("after Refresh the invoice is %v with %v pending, want open with 5 pending", invoice.Status, invoice.Pending)
+	}
+
+	api.set("get_tx_info", `{"status":100,"status_text":"complete","amountf":"0.5","receivedf":"0.5"}`)
+	if invoice, err = manager.Refresh("inv"); err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Status != InvoicePaid {
+		t.Errorf("after Refresh the invoice is %v, want paid", invoice.Status)
+	}
+	if _, err := manager.Pay("inv", "BTC"); err == nil {
+		t.Error("Pay of a paid invoice returned nil error")
+	}
+}
+
+func TestInvoicePayReleasesLock(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_transaction", `{"amount":"0.5","txn_id":"T1","timeout":3600}`)
+	manager := NewInvoiceManager(api.client(), NewMemoryInvoiceStore())
+	for _, id := range []string{"a", "b"} {
+		if _, err := manager.Create(id, "10", "USD", "buyer@example.com", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	api.hook = func(cmd string) {
+		if cmd == "create_transaction" {
+			close(entered)
+			<-release
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := manager.Pay("a", "BTC")
+		done <- err
+	}()
+	<-entered
+
+	handled := make(chan error)
+	go func() {
+		handled <- manager.HandleIPN(newTestIPN(t, "ipn_type", "api", "status", "0", "txn_id", "T2", "invoice", "b", "amount1", "10", "amount2", "0.5", "currency2", "BTC"))
+	}()
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("HandleIPN blocked while Pay was creating a transaction")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	invoice, _ := manager.Get("a")
+	if len(invoice.Attempts) != 1 {
+		t.Errorf("after Pay the invoice has attempts %+v, want one", invoice.Attempts)
+	}
+}
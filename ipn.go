@@ -1,12 +1,18 @@
 package coinpayments
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
+//ErrInvalidHMAC is returned when an IPN's HMAC does not match the IPN secret
+var ErrInvalidHMAC = errors.New("coinpayments: could not validate server HMAC")
+
 //IPN holds the data and type from an IPN
 type IPN struct {
 	ipnInformation
@@ -19,6 +25,16 @@ type IPN struct {
 	shoppingCartButtonFields      shoppingCartButtonFields
 	donationButtonFields          donationButtonFields
 	apiGeneratedTransactionFields apiGeneratedTransactionFields
+
+	ctx context.Context
+}
+
+//Context returns the context of the request the IPN arrived in, carrying any span started for it, or context.Background if it was not parsed from a request
+func (i *IPN) Context() context.Context {
+	if i.ctx == nil {
+		return context.Background()
+	}
+	return i.ctx
 }
 
 type ipnInformation struct {
@@ -159,24 +175,65 @@ func (i *IPN) ToApiIPN() (*apiIPN, error) {
 
 //ParseIPN takes a http request and parses the IPN information from it
 func (c *Client) ParseIPN(r *http.Request) (*IPN, error) {
+	ctx, span := c.tracer.Start(r.Context(), "coinpayments.ipn.parse")
+
+	start := time.Now()
+	ipn, data, category, err := c.readIPN(r)
+	c.observeIPN(ipn, category)
+	if c.logger != nil {
+		c.logger.logIPN(ctx, data, ipn, time.Since(start), category, err)
+	}
+
+	span.SetAttributes(ipnAttributes(ipn, category)...)
+	span.End(err)
+
+	if ipn != nil {
+		ipn.ctx = ctx
+	}
+	return ipn, err
+}
+
+//readIPN reads, parses and archives the IPN in the request, returning the raw body and the category of any error for instrumentation
+func (c *Client) readIPN(r *http.Request) (*IPN, []byte, ErrorCategory, error) {
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return nil, fmt.Errorf("coinpayments: error reading request body - %v", err)
+		return nil, nil, ErrorTransport, fmt.Errorf("coinpayments: error reading request body - %v", err)
 	}
 
-	if c.ipnSecret != "" {
-		hmac := r.Header.Get("HMAC")
+	ipn, err := c.parseIPN(data, r.Header.Get("HMAC"))
+	switch {
+	case err == ErrInvalidHMAC:
+		return nil, data, ErrorHMAC, err
+	case err != nil:
+		return nil, data, ErrorParse, err
+	}
+
+	if c.ipnArchive != nil {
+		if err := c.archiveIPN(data, r.Header, c.ipnSecret != ""); err != nil {
+			return nil, data, ErrorArchive, err
+		}
+	}
+	return ipn, data, ErrorNone, nil
+}
 
+//parseIPN parses a raw IPN body, checking its HMAC if the client has an IPN secret
+func (c *Client) parseIPN(data []byte, hmac string) (*IPN, error) {
+	if c.ipnSecret != "" {
 		genHMAC, err := c.makeIPNHMAC(string(data))
 		if err != nil {
 			return nil, fmt.Errorf("coinpayments: error generating ipn HMAC - %v", err)
 		}
 
 		if hmac != genHMAC {
-			return nil, fmt.Errorf("coinpayments: could not validate server HMAC")
+			return nil, ErrInvalidHMAC
 		}
 	}
 
+	return decodeIPN(data)
+}
+
+//decodeIPN decodes an IPN body without checking its HMAC
+func decodeIPN(data []byte) (*IPN, error) {
 	values, err := url.ParseQuery(string(data))
 	if err != nil {
 		return nil, err
@@ -611,3 +668,53 @@ type apiGeneratedTransactionFields struct {
 	ReceivedAmount   string
 	ReceivedConfirms string
 }
+
+//paymentFields are the fields shared by the button, cart, donation and api IPN types
+type paymentFields struct {
+	Status           string
+	StatusText       string
+	TransactionID    string
+	Currency1        string
+	Currency2        string
+	Amount1          string
+	Amount2          string
+	Fee              string
+	Invoice          string
+	Custom           string
+	ReceivedAmount   string
+	ReceivedConfirms string
+}
+
+//payment returns the payment fields of the IPN, or false if it is not a payment IPN
+func (i *IPN) payment() (paymentFields, bool) {
+	switch i.IPNType {
+	case "simple":
+		f := i.simpleButtonFields
+		return paymentFields{f.Status, f.StatusText, f.TransactionID, f.Currency1, f.Currency2, f.Amount1, f.Amount2, f.Fee, f.Invoice, f.Custom, f.ReceivedAmount, f.ReceivedConfirms}, true
+	case "button":
+		f := i.advancedButtonFields
+		return paymentFields{f.Status, f.StatusText, f.TransactionID, f.Currency1, f.Currency2, f.Amount1, f.Amount2, f.Fee, f.Invoice, f.Custom, f.ReceivedAmount, f.ReceivedConfirms}, true
+	case "cart":
+		f := i.shoppingCartButtonFields
+		return paymentFields{f.Status, f.StatusText, f.TransactionID, f.Currency1, f.Currency2, f.Amount1, f.Amount2, f.Fee, f.Invoice, f.Custom, f.ReceivedAmount, f.ReceivedConfirms}, true
+	case "donation":
+		f := i.donationButtonFields
+		return paymentFields{f.Status, f.StatusText, f.TransactionID, f.Currency1, f.Currency2, f.Amount1, f.Amount2, f.Fee, f.Invoice, f.Custom, f.ReceivedAmount, f.ReceivedConfirms}, true
+	case "api":
+		f := i.apiGeneratedTransactionFields
+		return paymentFields{f.Status, f.StatusText, f.TransactionID, f.Currency1, f.Currency2, f.Amount1, f.Amount2, f.Fee, f.Invoice, f.Custom, f.ReceivedAmount, f.ReceivedConfirms}, true
+	}
+	return paymentFields{}, false
+}
+
+//status returns the status code of any IPN type
+func (i *IPN) status() string {
+	switch i.IPNType {
+	case "deposit":
+		return i.depositInformation.Status
+	case "withdrawal":
+		return i.withdrawalInformation.Status
+	}
+	p, _ := i.payment()
+	return p.Status
+}
@@ -0,0 +1,209 @@
+package coinpayments
+
+import (
+	"net/http"
+	"sync"
+)
+
+//IPNHandlerFunc handles a parsed IPN, returning an error makes coinpayments resend it
+type IPNHandlerFunc func(ipn *IPN) error
+
+//IPNHandlerOption is an option used to modify an IPNHandler
+type IPNHandlerOption func(handler *IPNHandler)
+
+//defaultDecidedCapacity is how many decided transactions an IPNHandler remembers by default
+const defaultDecidedCapacity = 10000
+
+//IPNHandler is an http.Handler that parses IPNs and dispatches them to callbacks
+type IPNHandler struct {
+	client      *Client
+	handlers    []IPNHandlerFunc
+	onConfirmed []IPNHandlerFunc
+	onRejected  []IPNHandlerFunc
+	policy      *ConfirmationPolicy
+	capacity    int
+
+	mu      sync.Mutex
+	seq     uint64
+	decided map[string]uint64
+	order   []decidedKey
+}
+
+//decidedKey is a remembered decision in the order it was made, seq tells a later decision for the same key apart
+type decidedKey struct {
+	key string
+	seq uint64
+}
+
+//NewIPNHandler returns a new IPNHandler parsing with the client and with the applied options
+func NewIPNHandler(client *Client, options ...IPNHandlerOption) *IPNHandler {
+	handler := &IPNHandler{
+		client:   client,
+		capacity: defaultDecidedCapacity,
+		decided:  make(map[string]uint64),
+	}
+
+	for _, o := range options {
+		o(handler)
+	}
+	return handler
+}
+
+//WithIPNFunc is an option that calls fn for every IPN received
+func WithIPNFunc(fn IPNHandlerFunc) IPNHandlerOption {
+	return func(handler *IPNHandler) {
+		handler.handlers = append(handler.handlers, fn)
+	}
+}
+
+//WithConfirmedFunc is an option that calls fn once per transaction when it is sufficiently confirmed
+func WithConfirmedFunc(fn IPNHandlerFunc) IPNHandlerOption {
+	return func(handler *IPNHandler) {
+		handler.onConfirmed = append(handler.onConfirmed, fn)
+	}
+}
+
+//WithRejectedFunc is an option that calls fn once per transaction when the confirmation policy rejects it or coinpayments cancels it
+func WithRejectedFunc(fn IPNHandlerFunc) IPNHandlerOption {
+	return func(handler *IPNHandler) {
+		handler.onRejected = append(handler.onRejected, fn)
+	}
+}
+
+//WithDecidedCapacity is an option that sets how many transactions are remembered to call the confirmed and rejected funcs only once,
+//the oldest are forgotten first so a much later IPN for one of them calls the funcs again
+func WithDecidedCapacity(capacity int) IPNHandlerOption {
+	return func(handler *IPNHandler) {
+		handler.capacity = capacity
+	}
+}
+
+//WithConfirmationPolicy is an option that decides when payments count as confirmed, instead of waiting for coinpayments to complete them
+func WithConfirmationPolicy(policy *ConfirmationPolicy) IPNHandlerOption {
+	return func(handler *IPNHandler) {
+		handler.policy = policy
+	}
+}
+
+//ServeHTTP parses the IPN in the request and handles it, replying with an error status if it could not be handled
+func (h *IPNHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.client.tracer.Start(r.Context(), "coinpayments.ipn")
+
+	ipn, err := h.client.ParseIPN(r.WithContext(ctx))
+	if err != nil {
+		span.End(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category := ErrorNone
+	if err = h.Handle(ipn); err != nil {
+		category = ErrorHandler
+	}
+	span.SetAttributes(ipnAttributes(ipn, category)...)
+	span.End(err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("IPN OK"))
+}
+
+//Handle dispatches an already parsed IPN to the registered callbacks
+func (h *IPNHandler) Handle(ipn *IPN) error {
+	for _, fn := range h.handlers {
+		if err := fn(ipn); err != nil {
+			return err
+		}
+	}
+
+	if len(h.onConfirmed) == 0 && len(h.onRejected) == 0 {
+		return nil
+	}
+
+	txnID, decision, err := h.decide(ipn)
+	if err != nil {
+		return err
+	}
+
+	var funcs []IPNHandlerFunc
+	switch decision {
+	case ConfirmationAccept:
+		funcs = h.onConfirmed
+	case ConfirmationReject:
+		funcs = h.onRejected
+	}
+	if len(funcs) == 0 {
+		return nil
+	}
+
+	key := string(decision) + ":" + txnID
+	seq, first := h.remember(key)
+	if !first {
+		return nil
+	}
+
+	for _, fn := range funcs {
+		if err := fn(ipn); err != nil {
+			h.forget(key, seq)
+			return err
+		}
+	}
+	return nil
+}
+
+//decide returns the transaction id of a payment or deposit IPN and the confirmation policy's decision for it
+func (h *IPNHandler) decide(ipn *IPN) (string, ConfirmationDecision, error) {
+	var txnID string
+	if payment, ok := ipn.payment(); ok {
+		txnID = payment.TransactionID
+	} else if ipn.IPNType == "deposit" {
+		txnID = ipn.depositInformation.TransactionID
+	} else {
+		return "", ConfirmationWait, nil
+	}
+
+	policy := h.policy
+	if policy == nil {
+		policy = NewConfirmationPolicy()
+	}
+
+	decision, err := policy.DecideIPN(ipn)
+	if err != nil {
+		return "", ConfirmationWait, err
+	}
+	return txnID, decision, nil
+}
+
+//remember records a decision, returning false if it was already remembered, and forgets the oldest beyond the capacity
+func (h *IPNHandler) remember(key string) (uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.decided[key]; ok {
+		return 0, false
+	}
+
+	h.seq++
+	h.decided[key] = h.seq
+	h.order = append(h.order, decidedKey{key, h.seq})
+	for len(h.decided) > h.capacity && len(h.order) > 0 {
+		oldest := h.order[0]
+		h.order = h.order[1:]
+		if h.decided[oldest.key] == oldest.seq {
+			delete(h.decided, oldest.key)
+		}
+	}
+	return h.seq, true
+}
+
+//forget drops a decision whose callbacks failed so the next IPN retries them
+func (h *IPNHandler) forget(key string, seq uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.decided[key] == seq {
+		delete(h.decided, key)
+	}
+}
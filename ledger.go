@@ -0,0 +1,428 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Ledger accounts, each is kept per currency
+const (
+	//AccountWallet is the coinpayments wallet balance
+	AccountWallet = "wallet"
+	//AccountSales is the income side of payments received from buyers
+	AccountSales = "sales"
+	//AccountDeposits is the source of funds deposited to the wallet's addresses
+	AccountDeposits = "deposits"
+	//AccountFees is the coinpayments fees paid
+	AccountFees = "fees"
+	//AccountConversions is the clearing account for the two legs of a conversion
+	AccountConversions = "conversions"
+	//AccountTransfers is the destination of funds transferred to other coinpayments accounts
+	AccountTransfers = "transfers"
+	//AccountWithdrawals is the destination of funds withdrawn to external addresses
+	AccountWithdrawals = "withdrawals"
+	//AccountEquity is the source of opening balances
+	AccountEquity = "equity"
+)
+
+//LedgerPosting is one side of a ledger entry, positive amounts are debits and negative amounts are credits
+type LedgerPosting struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+	Amount   string `json:"amount"`
+}
+
+//LedgerEntry is a set of postings that sum to zero in every currency
+type LedgerEntry struct {
+	//ID identifies the movement the entry records, such as "payment:<txn_id>", and is posted at most once
+	ID       string          `json:"id"`
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Postings []LedgerPosting `json:"postings"`
+}
+
+//LedgerStore persists ledger entries
+type LedgerStore interface {
+	AppendEntry(entry LedgerEntry) error
+	ListEntries() ([]LedgerEntry, error)
+}
+
+//MemoryLedgerStore is a LedgerStore that keeps entries in memory
+type MemoryLedgerStore struct {
+	mu      sync.Mutex
+	entries []LedgerEntry
+}
+
+//NewMemoryLedgerStore returns an empty MemoryLedgerStore
+func NewMemoryLedgerStore() *MemoryLedgerStore {
+	return &MemoryLedgerStore{}
+}
+
+//AppendEntry stores a copy of the entry
+func (s *MemoryLedgerStore) AppendEntry(entry LedgerEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Postings = append([]LedgerPosting(nil), entry.Postings...)
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+//ListEntries returns copies of every stored entry in the order they were appended
+func (s *MemoryLedgerStore) ListEntries() ([]LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]LedgerEntry, len(s.entries))
+	for i, entry := range s.entries {
+		entry.Postings = append([]LedgerPosting(nil), entry.Postings...)
+		list[i] = entry
+	}
+	return list, nil
+}
+
+//LedgerDrift is a currency whose computed wallet balance disagrees with the "balances" command
+type LedgerDrift struct {
+	Currency   string `json:"currency"`
+	Computed   string `json:"computed"`
+	Reported   string `json:"reported"`
+	Difference string `json:"difference"`
+}
+
+//Ledger records wallet movements as double-entry postings per currency
+type Ledger struct {
+	client *Client
+	store  LedgerStore
+
+	mu     sync.Mutex
+	posted map[string]bool
+}
+
+//NewLedger returns a Ledger persisting to store, entries already in the store are not posted again
+func NewLedger(client *Client, store LedgerStore) (*Ledger, error) {
+	entries, err := store.ListEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &Ledger{client: client, store: store, posted: make(map[string]bool, len(entries))}
+	for _, entry := range entries {
+		ledger.posted[entry.ID] = true
+	}
+	return ledger, nil
+}
+
+//Post validates and appends the entry, returning false if an entry with the same id was already posted
+func (l *Ledger) Post(entry LedgerEntry) (bool, error) {
+	if entry.ID == "" {
+		return false, fmt.Errorf("coinpayments: ledger entry has no id")
+	}
+	entry.Postings = append([]LedgerPosting(nil), entry.Postings...)
+
+	sums := make(map[string]*big.Rat)
+	for i, p := range entry.Postings {
+		amount, err := parseAmount(p.Amount)
+		if err != nil {
+			return false, fmt.Errorf("coinpayments: invalid amount in ledger entry %v - %v", entry.ID, err)
+		}
+		coin := strings.ToUpper(p.Currency)
+		if sums[coin] == nil {
+			sums[coin] = new(big.Rat)
+		}
+		sums[coin].Add(sums[coin], amount)
+		entry.Postings[i].Currency = coin
+		entry.Postings[i].Amount = formatAmount(amount)
+	}
+	for coin, sum := range sums {
+		if sum.Sign() != 0 {
+			return false, fmt.Errorf("coinpayments: ledger entry %v does not balance in %v: %v", entry.ID, coin, formatAmount(sum))
+		}
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.posted[entry.ID] {
+		return false, nil
+	}
+	if err := l.store.AppendEntry(entry); err != nil {
+		return false, err
+	}
+	l.posted[entry.ID] = true
+	return true, nil
+}
+
+//Open posts an opening wallet balance for the currency against equity
+func (l *Ledger) Open(currency, amount string) error {
+	_, err := l.Post(LedgerEntry{
+		ID:   "open:" + strings.ToUpper(currency),
+		Kind: "open",
+		Postings: []LedgerPosting{
+			{AccountWallet, currency, amount},
+			{AccountEquity, currency, negate(amount)},
+		},
+	})
+	return err
+}
+
+//HandleIPN posts completed payments, deposits and withdrawals, ignoring other IPN types and incomplete ones,
+//withdrawal IPNs do not report network fees so those must be posted with RecordWithdrawalFee
+func (l *Ledger) HandleIPN(ipn *IPN) error {
+	if ipn.IPNType == "deposit" {
+		d := ipn.depositInformation
+		status, err := strconv.Atoi(d.Status)
+		if err != nil {
+			return fmt.Errorf("coinpayments: invalid deposit status %q - %v", d.Status, err)
+		}
+		if status < 100 {
+			return nil
+		}
+		return l.postReceipt("deposit:"+d.TransactionID, "deposit", AccountDeposits, d.Currency, d.Amount, d.Fee)
+	}
+
+	if ipn.IPNType == "withdrawal" {
+		w := ipn.withdrawalInformation
+		status, err := strconv.Atoi(w.Status)
+		if err != nil {
+			return fmt.Errorf("coinpayments: invalid withdrawal status %q - %v", w.Status, err)
+		}
+		if status != 2 {
+			return nil
+		}
+		return l.postSend("withdrawal:"+w.ID, "withdrawal", AccountWithdrawals, w.Currency, w.Amount, "")
+	}
+
+	p, ok := ipn.payment()
+	if !ok {
+		return nil
+	}
+	status, err := strconv.Atoi(p.Status)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid payment status %q - %v", p.Status, err)
+	}
+	if !paymentComplete(status) {
+		return nil
+	}
+	amount := p.ReceivedAmount
+	if amount == "" {
+		amount = p.Amount2
+	}
+	return l.postReceipt("payment:"+p.TransactionID, "payment", AccountSales, p.Currency2, amount, p.Fee)
+}
+
+//RecordWithdrawal posts a withdrawal from "get_withdrawal_info" once it has completed,
+//fee is the network fee the wallet paid on top of the amount, such as the "rates" tx_fee of a withdrawal made with add_tx_fee, or empty if the receiver paid it
+func (l *Ledger) RecordWithdrawal(id string, info *getWithdrawalInfoResponse, fee string) error {
+	if info.Status != 2 {
+		return nil
+	}
+	return l.postSend("withdrawal:"+id, "withdrawal", AccountWithdrawals, info.Coin, info.Amountf, fee)
+}
+
+//RecordWithdrawalFee posts the network fee the wallet paid on top of a withdrawal posted without one, such as from a withdrawal IPN
+func (l *Ledger) RecordWithdrawalFee(id, currency, fee string) error {
+	_, err := l.Post(LedgerEntry{
+		ID:   "withdrawal-fee:" + id,
+		Kind: "withdrawal-fee",
+		Postings: []LedgerPosting{
+			{AccountWallet, currency, negate(fee)},
+			{AccountFees, currency, fee},
+		},
+	})
+	return err
+}
+
+//RecordTransfer posts a transfer made with "create_transfer"
+func (l *Ledger) RecordTransfer(id, amount, currency string) error {
+	return l.postSend("transfer:"+id, "transfer", AccountTransfers, currency, amount, "")
+}
+
+//RecordConversion posts both legs of a completed conversion, ignoring conversions that have not completed
+func (l *Ledger) RecordConversion(record ConversionRecord) error {
+	if !record.Complete {
+		return nil
+	}
+
+	sent := record.AmountSent
+	if sent == "" {
+		sent = record.Amount
+	}
+	_, err := l.Post(LedgerEntry{
+		ID:   "conversion:" + record.ID,
+		Time: record.CompletedAt,
+		Kind: "conversion",
+		Postings: []LedgerPosting{
+			{AccountWallet, record.From, negate(sent)},
+			{AccountConversions, record.From, sent},
+			{AccountWallet, record.To, record.Received},
+			{AccountConversions, record.To, negate(record.Received)},
+		},
+	})
+	return err
+}
+
+//postReceipt posts funds received into the wallet from source with the fee taken out
+func (l *Ledger) postReceipt(id, kind, source, currency, amount, fee string) error {
+	if fee == "" {
+		fee = "0"
+	}
+	gross, err := parseAmount(amount)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid %v amount %q - %v", kind, amount, err)
+	}
+	f, err := parseAmount(fee)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid %v fee %q - %v", kind, fee, err)
+	}
+
+	_, err = l.Post(LedgerEntry{
+		ID:   id,
+		Kind: kind,
+		Postings: []LedgerPosting{
+			{AccountWallet, currency, formatAmount(new(big.Rat).Sub(gross, f))},
+			{AccountFees, currency, formatAmount(f)},
+			{source, currency, formatAmount(new(big.Rat).Neg(gross))},
+		},
+	})
+	return err
+}
+
+//postSend posts funds leaving the wallet to destination with any fee paid on top of them
+func (l *Ledger) postSend(id, kind, destination, currency, amount, fee string) error {
+	postings := []LedgerPosting{
+		{AccountWallet, currency, negate(amount)},
+		{destination, currency, amount},
+	}
+	if fee != "" {
+		postings = append(postings,
+			LedgerPosting{AccountWallet, currency, negate(fee)},
+			LedgerPosting{AccountFees, currency, fee},
+		)
+	}
+
+	_, err := l.Post(LedgerEntry{
+		ID:       id,
+		Kind:     kind,
+		Postings: postings,
+	})
+	return err
+}
+
+//Entries returns every posted entry
+func (l *Ledger) Entries() ([]LedgerEntry, error) {
+	return l.store.ListEntries()
+}
+
+//Balances recomputes the balance of an account per currency from the posted entries
+func (l *Ledger) Balances(account string) (map[string]string, error) {
+	sums, err := l.sums(account)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]string, len(sums))
+	for coin, sum := range sums {
+		balances[coin] = formatAmount(sum)
+	}
+	return balances, nil
+}
+
+func (l *Ledger) sums(account string) (map[string]*big.Rat, error) {
+	entries, err := l.store.ListEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]*big.Rat)
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			if p.Account != account {
+				continue
+			}
+			amount, err := parseAmount(p.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("coinpayments: invalid amount in ledger entry %v - %v", entry.ID, err)
+			}
+			if sums[p.Currency] == nil {
+				sums[p.Currency] = new(big.Rat)
+			}
+			sums[p.Currency].Add(sums[p.Currency], amount)
+		}
+	}
+	return sums, nil
+}
+
+//Drift compares the computed wallet balances with the "balances" command and returns the currencies that disagree
+func (l *Ledger) Drift() ([]LedgerDrift, error) {
+	return l.DriftContext(context.Background())
+}
+
+//DriftContext compares the computed wallet balances with the "balances" command with the context
+func (l *Ledger) DriftContext(ctx context.Context) ([]LedgerDrift, error) {
+	computed, err := l.sums(AccountWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.client.BalancesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reported := make(map[string]*big.Rat, len(*resp))
+	for coin, b := range *resp {
+		amount, err := parseAmount(b.Balancef)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: invalid balance for %v - %v", coin, err)
+		}
+		reported[strings.ToUpper(coin)] = amount
+	}
+
+	coins := make(map[string]bool)
+	for coin := range computed {
+		coins[coin] = true
+	}
+	for coin := range reported {
+		coins[coin] = true
+	}
+
+	var drift []LedgerDrift
+	for coin := range coins {
+		c, r := computed[coin], reported[coin]
+		if c == nil {
+			c = new(big.Rat)
+		}
+		if r == nil {
+			r = new(big.Rat)
+		}
+		if c.Cmp(r) == 0 {
+			continue
+		}
+		drift = append(drift, LedgerDrift{
+			Currency:   coin,
+			Computed:   formatAmount(c),
+			Reported:   formatAmount(r),
+			Difference: formatAmount(new(big.Rat).Sub(c, r)),
+		})
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Currency < drift[j].Currency })
+	return drift, nil
+}
+
+//negate returns the decimal amount with its sign flipped, leaving unparseable amounts for Post to reject
+func negate(amount string) string {
+	a, err := parseAmount(amount)
+	if err != nil {
+		return amount
+	}
+	return formatAmount(a.Neg(a))
+}
@@ -0,0 +1,269 @@
+package coinpayments
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func newTestLedger(t *testing.T, client *Client) *Ledger {
+	ledger, err := NewLedger(client, NewMemoryLedgerStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ledger
+}
+
+func TestLedgerPostBalances(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    LedgerEntry
+		wantErr  bool
+		postings []LedgerPosting
+	}{
+		{
+			name:  "balanced",
+			entry: LedgerEntry{ID: "a", Postings: []LedgerPosting{{AccountWallet, "btc", "1.50"}, {AccountEquity, "BTC", "-1.5"}}},
+			//currencies are upper cased and amounts normalized
+			postings: []LedgerPosting{{AccountWallet, "BTC", "1.5"}, {AccountEquity, "BTC", "-1.5"}},
+		},
+		{
+			name: "balanced per currency",
+			entry: LedgerEntry{ID: "b", Postings: []LedgerPosting{
+				{AccountWallet, "BTC", "-1"}, {AccountConversions, "BTC", "1"},
+				{AccountWallet, "LTC", "100"}, {AccountConversions, "LTC", "-100"},
+			}},
+			postings: []LedgerPosting{
+				{AccountWallet, "BTC", "-1"}, {AccountConversions, "BTC", "1"},
+				{AccountWallet, "LTC", "100"}, {AccountConversions, "LTC", "-100"},
+			},
+		},
+		{
+			name:    "unbalanced",
+			entry:   LedgerEntry{ID: "c", Postings: []LedgerPosting{{AccountWallet, "BTC", "1"}, {AccountEquity, "BTC", "-0.9"}}},
+			wantErr: true,
+		},
+		{
+			name:    "balanced only across currencies",
+			entry:   LedgerEntry{ID: "d", Postings: []LedgerPosting{{AccountWallet, "BTC", "1"}, {AccountEquity, "LTC", "-1"}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid amount",
+			entry:   LedgerEntry{ID: "e", Postings: []LedgerPosting{{AccountWallet, "BTC", "one"}, {AccountEquity, "BTC", "-1"}}},
+			wantErr: true,
+		},
+		{
+			name:    "no id",
+			entry:   LedgerEntry{Postings: []LedgerPosting{{AccountWallet, "BTC", "1"}, {AccountEquity, "BTC", "-1"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		ledger := newTestLedger(t, nil)
+		posted, err := ledger.Post(tt.entry)
+		if tt.wantErr {
+			if err == nil || posted {
+				t.Errorf("%v: Post returned %v, %v, want an error", tt.name, posted, err)
+			}
+			if entries, _ := ledger.Entries(); len(entries) != 0 {
+				t.Errorf("%v: Post stored %+v, want nothing", tt.name, entries)
+			}
+			continue
+		}
+
+		entries, _ := ledger.Entries()
+		if err != nil || !posted || len(entries) != 1 || !reflect.DeepEqual(entries[0].Postings, tt.postings) {
+			t.Errorf("%v: Post returned %v, %v and stored %+v, want postings %+v", tt.name, posted, err, entries, tt.postings)
+		}
+	}
+}
+
+func TestLedgerReceipts(t *testing.T) {
+	tests := []struct {
+		name   string
+		ipn    []string
+		source string
+		wallet string
+		fees   string
+	}{
+		{
+			name:   "payment",
+			ipn:    []string{"ipn_type", "api", "status", "100", "txn_id", "T1", "currency2", "BTC", "amount2", "1", "fee", "0.01"},
+			source: AccountSales, wallet: "0.99", fees: "0.01",
+		},
+		{
+			name:   "payment with a received amount",
+			ipn:    []string{"ipn_type", "api", "status", "100", "txn_id", "T1", "currency2", "BTC", "amount2", "1", "received_amount", "1.2", "fee", "0.01"},
+			source: AccountSales, wallet: "1.19", fees: "0.01",
+		},
+		{
+			name:   "payment without a fee",
+			ipn:    []string{"ipn_type", "simple", "status", "2", "txn_id", "T1", "currency2", "BTC", "amount2", "1"},
+			source: AccountSales, wallet: "1", fees: "0",
+		},
+		{
+			name:   "deposit",
+			ipn:    []string{"ipn_type", "deposit", "status", "100", "txn_id", "D1", "currency", "BTC", "amount", "0.5", "fee", "0.0025"},
+			source: AccountDeposits, wallet: "0.4975", fees: "0.0025",
+		},
+	}
+
+	for _, tt := range tests {
+		ledger := newTestLedger(t, nil)
+		if err := ledger.HandleIPN(newTestIPN(t, tt.ipn...)); err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+
+		wallet, _ := ledger.Balances(AccountWallet)
+		fees, _ := ledger.Balances(AccountFees)
+		source, _ := ledger.Balances(tt.source)
+		gross := addAmounts(t, tt.wallet, tt.fees)
+		if wallet["BTC"] != tt.wallet || fees["BTC"] != tt.fees || source["BTC"] != negate(gross) {
+			t.Errorf("%v: wallet %v fees %v %v %v, want %v, %v and -%v", tt.name, wallet["BTC"], fees["BTC"], tt.source, source["BTC"], tt.wallet, tt.fees, gross)
+		}
+	}
+}
+
+func addAmounts(t *testing.T, a, b string) string {
+	x, err := parseAmount(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := parseAmount(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return formatAmount(x.Add(x, y))
+}
+
+func TestLedgerIgnoresIncompleteIPNs(t *testing.T) {
+	ledger := newTestLedger(t, nil)
+	ipns := []*IPN{
+		newTestIPN(t, "ipn_type", "api", "status", "1", "txn_id", "T1", "currency2", "BTC", "amount2", "1"),
+		newTestIPN(t, "ipn_type", "deposit", "status", "1", "txn_id", "D1", "currency", "BTC", "amount", "1"),
+		newTestIPN(t, "ipn_type", "withdrawal", "status", "1", "id", "W1", "currency", "BTC", "amount", "1"),
+	}
+	for _, ipn := range ipns {
+		if err := ledger.HandleIPN(ipn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, _ := ledger.Entries(); len(entries) != 0 {
+		t.Errorf("incomplete IPNs posted %+v, want nothing", entries)
+	}
+}
+
+func TestLedgerPostsEachIDOnce(t *testing.T) {
+	store := NewMemoryLedgerStore()
+	ledger, err := NewLedger(nil, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipn := newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T1", "currency2", "BTC", "amount2", "1")
+	for i := 0; i < 2; i++ {
+		if err := ledger.HandleIPN(ipn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if posted, err := ledger.Post(LedgerEntry{ID: "payment:T1", Postings: []LedgerPosting{{AccountWallet, "BTC", "1"}, {AccountSales, "BTC", "-1"}}}); err != nil || posted {
+		t.Errorf("Post of a posted id returned %v, %v, want false", posted, err)
+	}
+
+	//a ledger reopened on the same store remembers what was posted
+	reopened, err := NewLedger(nil, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.HandleIPN(ipn); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := store.ListEntries(); len(entries) != 1 {
+		t.Errorf("store holds %v entries, want 1", len(entries))
+	}
+}
+
+func TestLedgerWithdrawalFees(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(ledger *Ledger) error
+		wallet string
+		fees   string
+	}{
+		{
+			name: "withdrawal with its fee",
+			record: func(ledger *Ledger) error {
+				return ledger.RecordWithdrawal("W1", &getWithdrawalInfoResponse{Status: 2, Coin: "BTC", Amountf: "1"}, "0.0005")
+			},
+			wallet: "-1.0005", fees: "0.0005",
+		},
+		{
+			name: "withdrawal paid by the receiver",
+			record: func(ledger *Ledger) error {
+				return ledger.RecordWithdrawal("W1", &getWithdrawalInfoResponse{Status: 2, Coin: "BTC", Amountf: "1"}, "")
+			},
+			wallet: "-1", fees: "",
+		},
+		{
+			name: "withdrawal IPN and its fee posted separately",
+			record: func(ledger *Ledger) error {
+				if err := ledger.HandleIPN(newTestIPN(t, "ipn_type", "withdrawal", "status", "2", "id", "W1", "currency", "BTC", "amount", "1")); err != nil {
+					return err
+				}
+				return ledger.RecordWithdrawalFee("W1", "BTC", "0.0005")
+			},
+			wallet: "-1.0005", fees: "0.0005",
+		},
+		{
+			name: "withdrawal not yet complete",
+			record: func(ledger *Ledger) error {
+				return ledger.RecordWithdrawal("W1", &getWithdrawalInfoResponse{Status: 1, Coin: "BTC", Amountf: "1"}, "0.0005")
+			},
+			wallet: "", fees: "",
+		},
+	}
+
+	for _, tt := range tests {
+		ledger := newTestLedger(t, nil)
+		if err := tt.record(ledger); err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+
+		wallet, _ := ledger.Balances(AccountWallet)
+		fees, _ := ledger.Balances(AccountFees)
+		withdrawals, _ := ledger.Balances(AccountWithdrawals)
+		wantWithdrawn := "1"
+		if tt.wallet == "" {
+			wantWithdrawn = ""
+		}
+		if wallet["BTC"] != tt.wallet || fees["BTC"] != tt.fees || withdrawals["BTC"] != wantWithdrawn {
+			t.Errorf("%v: wallet %q fees %q withdrawals %q, want %q, %q and %q", tt.name, wallet["BTC"], fees["BTC"], withdrawals["BTC"], tt.wallet, tt.fees, wantWithdrawn)
+		}
+	}
+}
+
+func TestLedgerDrift(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", `{"BTC":{"balancef":"1.5"},"LTC":{"balancef":"2"}}`)
+	ledger := newTestLedger(t, api.client())
+	if err := ledger.Open("BTC", "1.5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Open("DOGE", "10"); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := ledger.DriftContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LedgerDrift{
+		{Currency: "DOGE", Computed: "10", Reported: "0", Difference: "10"},
+		{Currency: "LTC", Computed: "0", Reported: "2", Difference: "-2"},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("DriftContext returned %+v, want %+v", drift, want)
+	}
+}
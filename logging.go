@@ -0,0 +1,141 @@
+package coinpayments
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Logger is a structured logger, *slog.Logger satisfies it
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+//LoggerOption is an option used to modify what a Client logs
+type LoggerOption func(config *loggerConfig)
+
+type loggerConfig struct {
+	logger           Logger
+	showAddresses    bool
+	showEmails       bool
+	redactedParams   map[string]bool
+	redactedSuffixes []string
+}
+
+//redactedValue replaces the value of redacted parameters in logs
+const redactedValue = "[REDACTED]"
+
+//addressParams are redacted unless WithLoggedAddresses is used
+var addressParams = []string{"address", "address1", "address2", "send_address", "payment_address"}
+
+//emailParams are redacted unless WithLoggedEmails is used
+var emailParams = []string{"email", "buyer_email"}
+
+//WithLogger is an option that makes the Client log api calls and IPNs to logger, redacting the api key, addresses and emails
+func WithLogger(logger Logger, options ...LoggerOption) ClientOption {
+	return func(client *Client) {
+		config := &loggerConfig{logger: logger, redactedParams: map[string]bool{"key": true}}
+		for _, o := range options {
+			o(config)
+		}
+
+		if !config.showAddresses {
+			for _, p := range addressParams {
+				config.redactedParams[p] = true
+				config.redactedSuffixes = append(config.redactedSuffixes, "["+p+"]")
+			}
+		}
+		if !config.showEmails {
+			for _, p := range emailParams {
+				config.redactedParams[p] = true
+			}
+		}
+		client.logger = config
+	}
+}
+
+//WithLoggedAddresses is an option that logs addresses instead of redacting them
+func WithLoggedAddresses() LoggerOption {
+	return func(config *loggerConfig) {
+		config.showAddresses = true
+	}
+}
+
+//WithLoggedEmails is an option that logs email addresses instead of redacting them
+func WithLoggedEmails() LoggerOption {
+	return func(config *loggerConfig) {
+		config.showEmails = true
+	}
+}
+
+//WithRedactedParams is an option that redacts more parameters, such as "first_name" or "phone"
+func WithRedactedParams(params ...string) LoggerOption {
+	return func(config *loggerConfig) {
+		if config.redactedParams == nil {
+			config.redactedParams = make(map[string]bool)
+		}
+		for _, p := range params {
+			config.redactedParams[p] = true
+		}
+	}
+}
+
+//params formats values for a log line, redacting the configured parameters and anything that looks like an email address
+func (l *loggerConfig) params(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			if l.redacted(k, v) {
+				v = redactedValue
+			}
+			b.WriteString(k + "=" + v)
+		}
+	}
+	return b.String()
+}
+
+func (l *loggerConfig) redacted(key, value string) bool {
+	if l.redactedParams[key] {
+		return true
+	}
+	for _, s := range l.redactedSuffixes {
+		if strings.HasSuffix(key, s) {
+			return true
+		}
+	}
+	return !l.showEmails && strings.Contains(value, "@")
+}
+
+func (l *loggerConfig) logCall(ctx context.Context, cmd, params string, latency time.Duration, category ErrorCategory, err error) {
+	if err != nil {
+		l.logger.ErrorContext(ctx, "coinpayments api call failed", "cmd", cmd, "params", params, "latency", latency, "error_category", string(category), "error", err.Error())
+		return
+	}
+	l.logger.DebugContext(ctx, "coinpayments api call", "cmd", cmd, "params", params, "latency", latency)
+}
+
+func (l *loggerConfig) logIPN(ctx context.Context, data []byte, ipn *IPN, latency time.Duration, category ErrorCategory, err error) {
+	values, _ := url.ParseQuery(string(data))
+	params := l.params(values)
+
+	if err != nil {
+		l.logger.WarnContext(ctx, "coinpayments ipn rejected", "params", params, "latency", latency, "error_category", string(category), "error", err.Error())
+		return
+	}
+	ipnType, status := ipnLabels(ipn)
+	l.logger.DebugContext(ctx, "coinpayments ipn", "ipn_type", ipnType, "status", status, "params", params, "latency", latency)
+}
@@ -0,0 +1,102 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//recordingLogger is a Logger keeping every line it is given
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) log(level, msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(append([]interface{}{level, msg}, args...)...))
+}
+
+func (l *recordingLogger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("DEBUG", msg, args...)
+}
+
+func (l *recordingLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("INFO", msg, args...)
+}
+
+func (l *recordingLogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("WARN", msg, args...)
+}
+
+func (l *recordingLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("ERROR", msg, args...)
+}
+
+func TestLogParamsRedaction(t *testing.T) {
+	values := url.Values{
+		"key":                 {"public"},
+		"address":             {"1BoatSLRHtKNngkdXEeobR76b53LETtpyT"},
+		"outputs[x][address]": {"addr2"},
+		"buyer_email":         {"buyer@example.com"},
+		"note":                {"ask me at someone@example.com"},
+		"phone":               {"555"},
+		"amount":              {"1"},
+	}
+
+	tests := []struct {
+		name    string
+		options []LoggerOption
+		want    string
+	}{
+		{
+			name: "default",
+			want: "address=[REDACTED] amount=1 buyer_email=[REDACTED] key=[REDACTED] note=[REDACTED] outputs[x][address]=[REDACTED] phone=555",
+		},
+		{
+			name:    "addresses shown",
+			options: []LoggerOption{WithLoggedAddresses()},
+			want:    "address=1BoatSLRHtKNngkdXEeobR76b53LETtpyT amount=1 buyer_email=[REDACTED] key=[REDACTED] note=[REDACTED] outputs[x][address]=addr2 phone=555",
+		},
+		{
+			name:    "emails shown and more redacted",
+			options: []LoggerOption{WithLoggedEmails(), WithRedactedParams("phone")},
+			want:    "address=[REDACTED] amount=1 buyer_email=buyer@example.com key=[REDACTED] note=ask me at someone@example.com outputs[x][address]=[REDACTED] phone=[REDACTED]",
+		},
+	}
+
+	for _, tt := range tests {
+		client := NewClient("", "", WithLogger(&recordingLogger{}, tt.options...))
+		if got := client.logger.params(values); got != tt.want {
+			t.Errorf("%v: params = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientLogsRedactedCalls(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_withdrawal", `{"id":"W1","status":0,"amount":"1"}`)
+	logger := &recordingLogger{}
+	client := api.client(WithLogger(logger))
+
+	if _, err := client.CreateWithdrawalContext(context.Background(), "1", "BTC", WithOptionalValue("address", "secret-address")); err != nil {
+		t.Fatal(err)
+	}
+	receiveTestIPN(t, client)
+
+	if len(logger.lines) != 2 {
+		t.Fatalf("logged %q, want a line for the call and the IPN", logger.lines)
+	}
+	for _, line := range logger.lines {
+		if strings.Contains(line, "secret-address") || strings.Contains(line, "public") || strings.Contains(line, "private") {
+			t.Errorf("logged %q, which leaks the address or keys", line)
+		}
+	}
+	if !strings.Contains(logger.lines[0], "create_withdrawal") || !strings.Contains(logger.lines[1], "txn_id=T1") {
+		t.Errorf("logged %q, want the command and the IPN fields", logger.lines)
+	}
+}
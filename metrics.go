@@ -0,0 +1,262 @@
+package coinpayments
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrorCategory classifies why an api call or IPN failed
+type ErrorCategory string
+
+//Error categories
+const (
+	//ErrorNone means the call or IPN succeeded
+	ErrorNone ErrorCategory = ""
+	//ErrorRequest means the api request could not be built or signed
+	ErrorRequest ErrorCategory = "request"
+	//ErrorTransport means the request could not be sent or its body could not be read
+	ErrorTransport ErrorCategory = "transport"
+	//ErrorHTTPStatus means the api answered with a status other than 200
+	ErrorHTTPStatus ErrorCategory = "http_status"
+	//ErrorDecode means the api response was not the expected json
+	ErrorDecode ErrorCategory = "decode"
+	//ErrorAPI means the api answered with an error message
+	ErrorAPI ErrorCategory = "api"
+	//ErrorMiddleware means a Middleware failed the call itself
+	ErrorMiddleware ErrorCategory = "middleware"
+	//ErrorArchive means a raw IPN could not be archived
+	ErrorArchive ErrorCategory = "archive"
+	//ErrorHMAC means an IPN's HMAC did not match the IPN secret
+	ErrorHMAC ErrorCategory = "hmac"
+	//ErrorParse means an IPN body could not be parsed
+	ErrorParse ErrorCategory = "parse"
+	//ErrorHandler means an IPNHandler callback failed to handle an IPN
+	ErrorHandler ErrorCategory = "handler"
+)
+
+//Metrics receives instrumentation from a Client
+type Metrics interface {
+	//ObserveCall is called after every api call with its duration and the category of its error, if any
+	ObserveCall(cmd string, duration time.Duration, category ErrorCategory)
+	//ObserveIPN is called after every IPN passed to ParseIPN, type and status are empty unless it parsed
+	ObserveIPN(ipnType, status string, category ErrorCategory)
+}
+
+//WithMetrics is an option that makes the Client report api calls and IPNs to metrics
+func WithMetrics(metrics Metrics) ClientOption {
+	return func(client *Client) {
+		client.metrics = metrics
+	}
+}
+
+//knownIPNTypes bounds the IPN type label, since unverified IPNs may carry anything
+var knownIPNTypes = map[string]bool{
+	"simple": true, "button": true, "cart": true, "donation": true, "deposit": true, "withdrawal": true, "api": true,
+}
+
+func (c *Client) observeIPN(ipn *IPN, category ErrorCategory) {
+	if c.metrics == nil {
+		return
+	}
+	if ipn == nil {
+		c.metrics.ObserveIPN("", "", category)
+		return
+	}
+
+	ipnType, status := ipnLabels(ipn)
+	c.metrics.ObserveIPN(ipnType, status, category)
+}
+
+//ipnLabels returns the type and status of the IPN, replacing unexpected values so they can be used as labels
+func ipnLabels(ipn *IPN) (string, string) {
+	ipnType := ipn.IPNType
+	if !knownIPNTypes[ipnType] {
+		ipnType = "unknown"
+	}
+	status := ipn.status()
+	if _, err := strconv.Atoi(status); err != nil {
+		status = "invalid"
+	}
+	return ipnType, status
+}
+
+//DefaultDurationBuckets are the histogram bucket bounds, in seconds, used when none are given
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type callKey struct {
+	cmd    string
+	result string
+}
+
+type ipnKey struct {
+	ipnType string
+	status  string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+//PrometheusMetrics is a Metrics that serves its counters in the Prometheus text exposition format
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	calls     map[callKey]uint64
+	durations map[string]*histogram
+	ipns      map[ipnKey]uint64
+	ipnErrors map[ErrorCategory]uint64
+}
+
+//NewPrometheusMetrics returns an empty PrometheusMetrics using the bucket bounds, or DefaultDurationBuckets if none are given
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusMetrics{
+		buckets:   buckets,
+		calls:     make(map[callKey]uint64),
+		durations: make(map[string]*histogram),
+		ipns:      make(map[ipnKey]uint64),
+		ipnErrors: make(map[ErrorCategory]uint64),
+	}
+}
+
+//ObserveCall counts the call and records its duration
+func (m *PrometheusMetrics) ObserveCall(cmd string, duration time.Duration, category ErrorCategory) {
+	result := string(category)
+	if category == ErrorNone {
+		result = "ok"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls[callKey{cmd, result}]++
+
+	h, ok := m.durations[cmd]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[cmd] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+//ObserveIPN counts the IPN by type and status, or by error category if it failed
+func (m *PrometheusMetrics) ObserveIPN(ipnType, status string, category ErrorCategory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if category != ErrorNone {
+		m.ipnErrors[category]++
+		return
+	}
+	m.ipns[ipnKey{ipnType, status}]++
+}
+
+//ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+//Write writes the metrics in the Prometheus text exposition format
+func (m *PrometheusMetrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	var b strings.Builder
+
+	b.WriteString("# HELP coinpayments_api_requests_total Number of api calls by command and result.\n")
+	b.WriteString("# TYPE coinpayments_api_requests_total counter\n")
+	calls := make([]callKey, 0, len(m.calls))
+	for k := range m.calls {
+		calls = append(calls, k)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].cmd != calls[j].cmd {
+			return calls[i].cmd < calls[j].cmd
+		}
+		return calls[i].result < calls[j].result
+	})
+	for _, k := range calls {
+		fmt.Fprintf(&b, "coinpayments_api_requests_total{cmd=%v,result=%v} %d\n", promLabel(k.cmd), promLabel(k.result), m.calls[k])
+	}
+
+	b.WriteString("# HELP coinpayments_api_request_duration_seconds Duration of api calls by command.\n")
+	b.WriteString("# TYPE coinpayments_api_request_duration_seconds histogram\n")
+	cmds := make([]string, 0, len(m.durations))
+	for cmd := range m.durations {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+	for _, cmd := range cmds {
+		h := m.durations[cmd]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(&b, "coinpayments_api_request_duration_seconds_bucket{cmd=%v,le=%v} %d\n", promLabel(cmd), promLabel(promFloat(bound)), h.counts[i])
+		}
+		fmt.Fprintf(&b, "coinpayments_api_request_duration_seconds_bucket{cmd=%v,le=\"+Inf\"} %d\n", promLabel(cmd), h.count)
+		fmt.Fprintf(&b, "coinpayments_api_request_duration_seconds_sum{cmd=%v} %v\n", promLabel(cmd), promFloat(h.sum))
+		fmt.Fprintf(&b, "coinpayments_api_request_duration_seconds_count{cmd=%v} %d\n", promLabel(cmd), h.count)
+	}
+
+	b.WriteString("# HELP coinpayments_ipn_received_total Number of parsed IPNs by type and status.\n")
+	b.WriteString("# TYPE coinpayments_ipn_received_total counter\n")
+	ipns := make([]ipnKey, 0, len(m.ipns))
+	for k := range m.ipns {
+		ipns = append(ipns, k)
+	}
+	sort.Slice(ipns, func(i, j int) bool {
+		if ipns[i].ipnType != ipns[j].ipnType {
+			return ipns[i].ipnType < ipns[j].ipnType
+		}
+		return ipns[i].status < ipns[j].status
+	})
+	for _, k := range ipns {
+		fmt.Fprintf(&b, "coinpayments_ipn_received_total{type=%v,status=%v} %d\n", promLabel(k.ipnType), promLabel(k.status), m.ipns[k])
+	}
+
+	b.WriteString("# HELP coinpayments_ipn_errors_total Number of rejected IPNs by error category.\n")
+	b.WriteString("# TYPE coinpayments_ipn_errors_total counter\n")
+	categories := make([]string, 0, len(m.ipnErrors))
+	for c := range m.ipnErrors {
+		categories = append(categories, string(c))
+	}
+	sort.Strings(categories)
+	for _, c := range categories {
+		fmt.Fprintf(&b, "coinpayments_ipn_errors_total{category=%v} %d\n", promLabel(c), m.ipnErrors[ErrorCategory(c)])
+	}
+	m.mu.Unlock()
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("coinpayments: error writing metrics - %v", err)
+	}
+	return nil
+}
+
+//promLabel quotes a label value as the exposition format requires
+func promLabel(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func promFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
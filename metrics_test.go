@@ -0,0 +1,89 @@
+package coinpayments
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	m := NewPrometheusMetrics(0.5, 0.1)
+	m.ObserveCall("rates", 50*time.Millisecond, ErrorNone)
+	m.ObserveCall("rates", 250*time.Millisecond, ErrorAPI)
+	m.ObserveCall("balances", 2*time.Second, ErrorNone)
+	m.ObserveIPN("api", "100", ErrorNone)
+	m.ObserveIPN("api", "100", ErrorNone)
+	m.ObserveIPN("", "", ErrorHMAC)
+
+	want := `# HELP coinpayments_api_requests_total Number of api calls by command and result.
+# TYPE coinpayments_api_requests_total counter
+coinpayments_api_requests_total{cmd="balances",result="ok"} 1
+coinpayments_api_requests_total{cmd="rates",result="api"} 1
+coinpayments_api_requests_total{cmd="rates",result="ok"} 1
+# HELP coinpayments_api_request_duration_seconds Duration of api calls by command.
+# TYPE coinpayments_api_request_duration_seconds histogram
+coinpayments_api_request_duration_seconds_bucket{cmd="balances",le="0.1"} 0
+coinpayments_api_request_duration_seconds_bucket{cmd="balances",le="0.5"} 0
+coinpayments_api_request_duration_seconds_bucket{cmd="balances",le="+Inf"} 1
+coinpayments_api_request_duration_seconds_sum{cmd="balances"} 2
+coinpayments_api_request_duration_seconds_count{cmd="balances"} 1
+coinpayments_api_request_duration_seconds_bucket{cmd="rates",le="0.1"} 1
+coinpayments_api_request_duration_seconds_bucket{cmd="rates",le="0.5"} 2
+coinpayments_api_request_duration_seconds_bucket{cmd="rates",le="+Inf"} 2
+coinpayments_api_request_duration_seconds_sum{cmd="rates"} 0.3
+coinpayments_api_request_duration_seconds_count{cmd="rates"} 2
+# HELP coinpayments_ipn_received_total Number of parsed IPNs by type and status.
+# TYPE coinpayments_ipn_received_total counter
+coinpayments_ipn_received_total{type="api",status="100"} 2
+# HELP coinpayments_ipn_errors_total Number of rejected IPNs by error category.
+# TYPE coinpayments_ipn_errors_total counter
+coinpayments_ipn_errors_total{category="hmac"} 1
+`
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Body.String(); got != want {
+		t.Errorf("exposition is\n%v\nwant\n%v", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type is %q, want the text exposition format", ct)
+	}
+}
+
+func TestPromLabelEscapes(t *testing.T) {
+	if got, want := promLabel("a\"b\\c\nd"), `"a\"b\\c\nd"`; got != want {
+		t.Errorf("promLabel = %v, want %v", got, want)
+	}
+}
+
+func TestClientReportsMetrics(t *testing.T) {
+	api := newFakeAPI()
+	api.set("rates", `{}`)
+	api.fail("balances", "invalid key")
+	m := NewPrometheusMetrics()
+	client := api.client(WithMetrics(m))
+
+	client.RatesContext(context.Background())
+	client.BalancesContext(context.Background())
+	receiveTestIPN(t, client)
+	if _, err := client.ParseIPN(httptest.NewRequest("POST", "/ipn", strings.NewReader("ipn_type=evil&status=x"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := m.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`coinpayments_api_requests_total{cmd="rates",result="ok"} 1`,
+		`coinpayments_api_requests_total{cmd="balances",result="api"} 1`,
+		`coinpayments_ipn_received_total{type="api",status="100"} 1`,
+		`coinpayments_ipn_received_total{type="unknown",status="invalid"} 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("metrics are missing %v:\n%v", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package coinpayments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+//CallFunc performs an api call with the unsigned parameters, returning the raw json response,
+//which is also returned alongside the error when the api reports one
+type CallFunc func(ctx context.Context, cmd string, params url.Values) ([]byte, error)
+
+//Middleware wraps a CallFunc to add behavior around api calls, it sees the parameters before they are signed
+//and the raw response once its envelope has been checked
+type Middleware func(next CallFunc) CallFunc
+
+//WithMiddleware is an option that wraps every api call in the middleware, the first registered runs outermost
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(client *Client) {
+		client.middleware = append(client.middleware, middleware...)
+	}
+}
+
+//ErrCommandBlocked is returned for api calls refused by BlockCommands
+var ErrCommandBlocked = errors.New("coinpayments: command blocked")
+
+//BlockCommands returns a Middleware that refuses the commands, such as "create_withdrawal" in a staging environment
+func BlockCommands(cmds ...string) Middleware {
+	blocked := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		blocked[cmd] = true
+	}
+
+	return func(next CallFunc) CallFunc {
+		return func(ctx context.Context, cmd string, params url.Values) ([]byte, error) {
+			if blocked[cmd] {
+				return nil, fmt.Errorf("%w: %v", ErrCommandBlocked, cmd)
+			}
+			return next(ctx, cmd, params)
+		}
+	}
+}
+
+//Envelope is the part of an api response shared by every command
+type Envelope struct {
+	Error  string          `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+//DecodeEnvelope decodes a raw api response, returning an error if it is malformed or reports an api error
+func DecodeEnvelope(body []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, &categorizedError{ErrorDecode, fmt.Errorf("coinpayments: error unmarshaling api error response - %v", err)}
+	}
+
+	if envelope.Error != apiSuccess {
+		return &envelope, &categorizedError{ErrorAPI, fmt.Errorf("coinpayments: api error - %v", envelope.Error)}
+	}
+	return &envelope, nil
+}
+
+//categorizedError carries the category of an error through the middleware chain
+type categorizedError struct {
+	category ErrorCategory
+	err      error
+}
+
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categorizedError) Unwrap() error {
+	return e.err
+}
+
+//categorize returns the category of an error from the middleware chain and the error to report, errors from middleware itself are categorized as ErrorMiddleware
+func categorize(err error) (ErrorCategory, error) {
+	var ce *categorizedError
+	if !errors.As(err, &ce) {
+		return ErrorMiddleware, err
+	}
+	if err == error(ce) {
+		return ce.category, ce.err
+	}
+	return ce.category, err
+}
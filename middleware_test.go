@@ -0,0 +1,121 @@
+package coinpayments
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	api := newFakeAPI()
+	api.set("rates", `{}`)
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next CallFunc) CallFunc {
+			return func(ctx context.Context, cmd string, params url.Values) ([]byte, error) {
+				order = append(order, name+" before "+cmd)
+				params.Set("seen_by_"+name, "1")
+				body, err := next(ctx, cmd, params)
+				order = append(order, name+" after")
+				return body, err
+			}
+		}
+	}
+
+	client := api.client(WithMiddleware(trace("outer"), trace("middle")), WithMiddleware(trace("inner")))
+	if _, err := client.Rates(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"outer before rates", "middle before rates", "inner before rates", "inner after", "middle after", "outer after"}
+	if strings.Join(order, ", ") != strings.Join(want, ", ") {
+		t.Errorf("middleware ran %v, want %v", order, want)
+	}
+	for _, name := range []string{"outer", "middle", "inner"} {
+		if api.calls[0].Get("seen_by_"+name) != "1" {
+			t.Errorf("the request is missing the parameter set by %v middleware: %v", name, api.calls[0])
+		}
+	}
+}
+
+func TestBlockCommands(t *testing.T) {
+	api := newFakeAPI()
+	api.set("rates", `{}`)
+	client := api.client(WithMiddleware(BlockCommands("create_withdrawal", "create_mass_withdrawal")))
+
+	if _, err := client.CreateWithdrawal("1", "BTC"); !errors.Is(err, ErrCommandBlocked) {
+		t.Errorf("CreateWithdrawal returned %v, want ErrCommandBlocked", err)
+	}
+	if n := api.count("create_withdrawal"); n != 0 {
+		t.Errorf("a blocked command reached the api %v times", n)
+	}
+	if _, err := client.Rates(); err != nil {
+		t.Errorf("Rates returned %v, want it to pass through", err)
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	apiErr := &categorizedError{ErrorAPI, errors.New("api error")}
+	tests := []struct {
+		err      error
+		category ErrorCategory
+	}{
+		{apiErr, ErrorAPI},
+		{&categorizedError{ErrorTransport, errors.New("reset")}, ErrorTransport},
+		{errors.New("rate limited"), ErrorMiddleware},
+	}
+
+	for _, tt := range tests {
+		if category, _ := categorize(tt.err); category != tt.category {
+			t.Errorf("categorize(%v) = %v, want %v", tt.err, category, tt.category)
+		}
+	}
+
+	wrapped := errors.New("wrapped")
+	if category, err := categorize(&wrapError{wrapped, apiErr}); category != ErrorAPI || err.Error() != "wrapped" {
+		t.Errorf("categorize of a wrapped api error returned %v, %v, want %v and the wrapping error", category, err, ErrorAPI)
+	}
+}
+
+//wrapError is an error a middleware returns around the error of the next CallFunc
+type wrapError struct {
+	err, next error
+}
+
+func (e *wrapError) Error() string {
+	return e.err.Error()
+}
+
+func (e *wrapError) Unwrap() error {
+	return e.next
+}
+
+func TestCallAPIError(t *testing.T) {
+	api := newFakeAPI()
+	api.fail("balances", "Invalid permissions")
+
+	var category ErrorCategory
+	var body []byte
+	client := api.client(WithMiddleware(func(next CallFunc) CallFunc {
+		return func(ctx context.Context, cmd string, params url.Values) ([]byte, error) {
+			b, err := next(ctx, cmd, params)
+			body = b
+			category, _ = categorize(err)
+			return b, err
+		}
+	}))
+
+	_, err := client.Balances()
+	if err == nil || !strings.Contains(err.Error(), "Invalid permissions") {
+		t.Fatalf("Balances returned %v, want the api error", err)
+	}
+	if category != ErrorAPI {
+		t.Errorf("category is %v, want %v", category, ErrorAPI)
+	}
+	if envelope, _ := DecodeEnvelope(body); envelope == nil || envelope.Error != "Invalid permissions" {
+		t.Errorf("middleware saw body %q, want the failed envelope", body)
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/aidenesco/coinpayments/otelcoinpayments
+
+go 1.14
+
+require (
+	github.com/aidenesco/coinpayments v0.1.0
+	go.opentelemetry.io/otel v1.21.0
+	go.opentelemetry.io/otel/trace v1.21.0
+)
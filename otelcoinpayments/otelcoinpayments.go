@@ -0,0 +1,60 @@
+//Package otelcoinpayments adapts an OpenTelemetry tracer to the coinpayments.Tracer interface
+package otelcoinpayments
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aidenesco/coinpayments"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//Tracer is a coinpayments.Tracer that starts OpenTelemetry spans
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+//NewTracer returns a Tracer starting spans with tracer, for example otel.Tracer("github.com/aidenesco/coinpayments")
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+//Start starts an OpenTelemetry span, api calls are client spans and IPN handling is a server span
+func (t *Tracer) Start(ctx context.Context, name string, attributes ...coinpayments.SpanAttribute) (context.Context, coinpayments.Span) {
+	kind := trace.SpanKindClient
+	switch {
+	case name == "coinpayments.ipn":
+		kind = trace.SpanKindServer
+	case strings.HasPrefix(name, "coinpayments.ipn."):
+		kind = trace.SpanKindInternal
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(keyValues(attributes)...))
+	return ctx, &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetAttributes(attributes ...coinpayments.SpanAttribute) {
+	s.span.SetAttributes(keyValues(attributes)...)
+}
+
+func (s *otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func keyValues(attributes []coinpayments.SpanAttribute) []attribute.KeyValue {
+	kv := make([]attribute.KeyValue, len(attributes))
+	for i, a := range attributes {
+		kv[i] = attribute.String(a.Key, a.Value)
+	}
+	return kv
+}
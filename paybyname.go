@@ -1,6 +1,9 @@
 package coinpayments
 
-import "net/url"
+import (
+	"context"
+	"net/url"
+)
 
 //getPBNInfoResponse is the api response of a "get_pbn_info" call
 type getPBNInfoResponse struct {
@@ -22,6 +25,11 @@ type getPBNInfoResponse struct {
 
 //GetPBNInfo calls the "get_pbn_info" command
 func (c *Client) GetPBNInfo(pbntag string, optionals ...OptionalValue) (*getPBNInfoResponse, error) {
+	return c.GetPBNInfoContext(context.Background(), pbntag, optionals...)
+}
+
+//GetPBNInfoContext calls the "get_pbn_info" command with the context
+func (c *Client) GetPBNInfoContext(ctx context.Context, pbntag string, optionals ...OptionalValue) (*getPBNInfoResponse, error) {
 	values := &url.Values{}
 	values.Set("pbntag", pbntag)
 	addOptionals(optionals, values)
@@ -30,7 +38,7 @@ func (c *Client) GetPBNInfo(pbntag string, optionals ...OptionalValue) (*getPBNI
 		errResponse
 		Result *getPBNInfoResponse `json:"result"`
 	}
-	if err := c.call("get_pbn_info", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_pbn_info", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -46,6 +54,11 @@ type getPBNListResponse []struct {
 
 //GetPBNList calls the "get_pbn_list" command
 func (c *Client) GetPBNList(optionals ...OptionalValue) (*getPBNListResponse, error) {
+	return c.GetPBNListContext(context.Background(), optionals...)
+}
+
+//GetPBNListContext calls the "get_pbn_list" command with the context
+func (c *Client) GetPBNListContext(ctx context.Context, optionals ...OptionalValue) (*getPBNListResponse, error) {
 	values := &url.Values{}
 	addOptionals(optionals, values)
 
@@ -53,7 +66,7 @@ func (c *Client) GetPBNList(optionals ...OptionalValue) (*getPBNListResponse, er
 		errResponse
 		Result *getPBNListResponse `json:"result"`
 	}
-	if err := c.call("get_pbn_list", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_pbn_list", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -65,6 +78,11 @@ type buyPBNTagsResponse []interface{}
 
 //BuyPBNTags calls the "buy_pbn_tags" command
 func (c *Client) BuyPBNTags(coin string, optionals ...OptionalValue) (*buyPBNTagsResponse, error) {
+	return c.BuyPBNTagsContext(context.Background(), coin, optionals...)
+}
+
+//BuyPBNTagsContext calls the "buy_pbn_tags" command with the context
+func (c *Client) BuyPBNTagsContext(ctx context.Context, coin string, optionals ...OptionalValue) (*buyPBNTagsResponse, error) {
 	values := &url.Values{}
 	values.Set("coin", coin)
 	addOptionals(optionals, values)
@@ -73,7 +91,7 @@ func (c *Client) BuyPBNTags(coin string, optionals ...OptionalValue) (*buyPBNTag
 		errResponse
 		Result *buyPBNTagsResponse `json:"result"`
 	}
-	if err := c.call("buy_pbn_tags", values, &resp); err != nil {
+	if err := c.callContext(ctx, "buy_pbn_tags", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -85,6 +103,11 @@ type claimPBNTagResponse []interface{}
 
 //ClaimPBNTag calls the "claim_pbn_tag" command
 func (c *Client) ClaimPBNTag(tagid, name string, optionals ...OptionalValue) (*claimPBNTagResponse, error) {
+	return c.ClaimPBNTagContext(context.Background(), tagid, name, optionals...)
+}
+
+//ClaimPBNTagContext calls the "claim_pbn_tag" command with the context
+func (c *Client) ClaimPBNTagContext(ctx context.Context, tagid, name string, optionals ...OptionalValue) (*claimPBNTagResponse, error) {
 	values := &url.Values{}
 	values.Set("tagid", tagid)
 	values.Set("name", name)
@@ -94,7 +117,7 @@ func (c *Client) ClaimPBNTag(tagid, name string, optionals ...OptionalValue) (*c
 		errResponse
 		Result *claimPBNTagResponse `json:"result"`
 	}
-	if err := c.call("claim_pbn_tag", values, &resp); err != nil {
+	if err := c.callContext(ctx, "claim_pbn_tag", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -106,6 +129,11 @@ type updatePBNTagResponse []interface{}
 
 //UpdatePBNTag calls the "update_pbn_tag" command
 func (c *Client) UpdatePBNTag(tagid string, optionals ...OptionalValue) (*updatePBNTagResponse, error) {
+	return c.UpdatePBNTagContext(context.Background(), tagid, optionals...)
+}
+
+//UpdatePBNTagContext calls the "update_pbn_tag" command with the context
+func (c *Client) UpdatePBNTagContext(ctx context.Context, tagid string, optionals ...OptionalValue) (*updatePBNTagResponse, error) {
 	values := &url.Values{}
 	values.Set("tagid", tagid)
 	addOptionals(optionals, values)
@@ -114,7 +142,7 @@ func (c *Client) UpdatePBNTag(tagid string, optionals ...OptionalValue) (*update
 		errResponse
 		Result *updatePBNTagResponse `json:"result"`
 	}
-	if err := c.call("update_pbn_tag", values, &resp); err != nil {
+	if err := c.callContext(ctx, "update_pbn_tag", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -126,6 +154,11 @@ type renewPBNTagResponse []interface{}
 
 //RenewPBNTag calls the "renew_pbn_tag" command
 func (c *Client) RenewPBNTag(tagid, coin string, optionals ...OptionalValue) (*renewPBNTagResponse, error) {
+	return c.RenewPBNTagContext(context.Background(), tagid, coin, optionals...)
+}
+
+//RenewPBNTagContext calls the "renew_pbn_tag" command with the context
+func (c *Client) RenewPBNTagContext(ctx context.Context, tagid, coin string, optionals ...OptionalValue) (*renewPBNTagResponse, error) {
 	values := &url.Values{}
 	values.Set("tagid", tagid)
 	values.Set("coin", coin)
@@ -135,7 +168,7 @@ func (c *Client) RenewPBNTag(tagid, coin string, optionals ...OptionalValue) (*r
 		errResponse
 		Result *renewPBNTagResponse `json:"result"`
 	}
-	if err := c.call("renew_pbn_tag", values, &resp); err != nil {
+	if err := c.callContext(ctx, "renew_pbn_tag", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -147,6 +180,11 @@ type deletePBNTagResponse []interface{}
 
 //DeletePBNTag calls the "delete_pbn_tag" command
 func (c *Client) DeletePBNTag(tagid string, optionals ...OptionalValue) (*deletePBNTagResponse, error) {
+	return c.DeletePBNTagContext(context.Background(), tagid, optionals...)
+}
+
+//DeletePBNTagContext calls the "delete_pbn_tag" command with the context
+func (c *Client) DeletePBNTagContext(ctx context.Context, tagid string, optionals ...OptionalValue) (*deletePBNTagResponse, error) {
 	values := &url.Values{}
 	values.Set("tagid", tagid)
 	addOptionals(optionals, values)
@@ -155,7 +193,7 @@ func (c *Client) DeletePBNTag(tagid string, optionals ...OptionalValue) (*delete
 		errResponse
 		Result *deletePBNTagResponse `json:"result"`
 	}
-	if err := c.call("delete_pbn_tag", values, &resp); err != nil {
+	if err := c.callContext(ctx, "delete_pbn_tag", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -169,6 +207,11 @@ type claimPBNCouponResponse struct {
 
 //ClaimPBNCoupon calls the "claim_pbn_coupon" command
 func (c *Client) ClaimPBNCoupon(coupon string, optionals ...OptionalValue) (*claimPBNCouponResponse, error) {
+	return c.ClaimPBNCouponContext(context.Background(), coupon, optionals...)
+}
+
+//ClaimPBNCouponContext calls the "claim_pbn_coupon" command with the context
+func (c *Client) ClaimPBNCouponContext(ctx context.Context, coupon string, optionals ...OptionalValue) (*claimPBNCouponResponse, error) {
 	values := &url.Values{}
 	values.Set("coupon", coupon)
 	addOptionals(optionals, values)
@@ -177,7 +220,7 @@ func (c *Client) ClaimPBNCoupon(coupon string, optionals ...OptionalValue) (*cla
 		errResponse
 		Result *claimPBNCouponResponse `json:"result"`
 	}
-	if err := c.call("claim_pbn_coupon", values, &resp); err != nil {
+	if err := c.callContext(ctx, "claim_pbn_coupon", values, &resp); err != nil {
 		return nil, err
 	}
 
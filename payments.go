@@ -1,6 +1,9 @@
 package coinpayments
 
-import "net/url"
+import (
+	"context"
+	"net/url"
+)
 
 //createTransactionResponse is the api response of a "create_transaction" call
 type createTransactionResponse struct {
@@ -17,6 +20,11 @@ type createTransactionResponse struct {
 
 //CreateTransaction calls the "create_transaction" command
 func (c *Client) CreateTransaction(amount, currency1, currency2, buyerEmail string, optionals ...OptionalValue) (*createTransactionResponse, error) {
+	return c.CreateTransactionContext(context.Background(), amount, currency1, currency2, buyerEmail, optionals...)
+}
+
+//CreateTransactionContext calls the "create_transaction" command with the context
+func (c *Client) CreateTransactionContext(ctx context.Context, amount, currency1, currency2, buyerEmail string, optionals ...OptionalValue) (*createTransactionResponse, error) {
 	values := &url.Values{}
 	values.Set("amount", amount)
 	values.Set("currency1", currency1)
@@ -29,7 +37,7 @@ func (c *Client) CreateTransaction(amount, currency1, currency2, buyerEmail stri
 		Result *createTransactionResponse `json:"result"`
 	}
 
-	if err := c.call("create_transaction", values, &resp); err != nil {
+	if err := c.callContext(ctx, "create_transaction", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -45,6 +53,11 @@ type getCallbackAddressResponse struct {
 
 //GetCallbackAddress calls the "get_callback_address" command
 func (c *Client) GetCallbackAddress(currency string, optionals ...OptionalValue) (*getCallbackAddressResponse, error) {
+	return c.GetCallbackAddressContext(context.Background(), currency, optionals...)
+}
+
+//GetCallbackAddressContext calls the "get_callback_address" command with the context
+func (c *Client) GetCallbackAddressContext(ctx context.Context, currency string, optionals ...OptionalValue) (*getCallbackAddressResponse, error) {
 	values := &url.Values{}
 	values.Set("currency", currency)
 	addOptionals(optionals, values)
@@ -54,7 +67,7 @@ func (c *Client) GetCallbackAddress(currency string, optionals ...OptionalValue)
 		Result *getCallbackAddressResponse `json:"result"`
 	}
 
-	if err := c.call("get_callback_address", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_callback_address", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -92,6 +105,11 @@ type getTxInfoResponse struct {
 
 //GetTxInfo calls the "get_tx_info" command
 func (c *Client) GetTxInfo(txid string, optionals ...OptionalValue) (*getTxInfoResponse, error) {
+	return c.GetTxInfoContext(context.Background(), txid, optionals...)
+}
+
+//GetTxInfoContext calls the "get_tx_info" command with the context
+func (c *Client) GetTxInfoContext(ctx context.Context, txid string, optionals ...OptionalValue) (*getTxInfoResponse, error) {
 	values := &url.Values{}
 	values.Set("txid", txid)
 	addOptionals(optionals, values)
@@ -101,7 +119,7 @@ func (c *Client) GetTxInfo(txid string, optionals ...OptionalValue) (*getTxInfoR
 		Result *getTxInfoResponse `json:"result"`
 	}
 
-	if err := c.call("get_tx_info", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_tx_info", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -120,13 +138,18 @@ type getTxInfoMultiResponse map[string]struct {
 	Amount           int    `json:"amount"`
 	Amountf          string `json:"amountf"`
 	Received         int    `json:"received"`
-	Recievedf        string `json:"recievedf"`
+	Recievedf        string `json:"receivedf"`
 	RecievedConfirms int    `json:"recv_confirms"`
 	PaymentAddress   string `json:"payment_address"`
 }
 
 //GetTxInfoMulti calls the "get_tx_info_multi" command
 func (c *Client) GetTxInfoMulti(txid string, optionals ...OptionalValue) (*getTxInfoMultiResponse, error) {
+	return c.GetTxInfoMultiContext(context.Background(), txid, optionals...)
+}
+
+//GetTxInfoMultiContext calls the "get_tx_info_multi" command with the context
+func (c *Client) GetTxInfoMultiContext(ctx context.Context, txid string, optionals ...OptionalValue) (*getTxInfoMultiResponse, error) {
 	values := &url.Values{}
 	values.Set("txid", txid)
 	addOptionals(optionals, values)
@@ -136,7 +159,7 @@ func (c *Client) GetTxInfoMulti(txid string, optionals ...OptionalValue) (*getTx
 		Result *getTxInfoMultiResponse `json:"result"`
 	}
 
-	if err := c.call("get_tx_info_multi", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_tx_info_multi", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -148,6 +171,11 @@ type getTxIdsResponse []string
 
 //GetTxIds calls the "get_tx_ids" command
 func (c *Client) GetTxIds(optionals ...OptionalValue) (*getTxIdsResponse, error) {
+	return c.GetTxIdsContext(context.Background(), optionals...)
+}
+
+//GetTxIdsContext calls the "get_tx_ids" command with the context
+func (c *Client) GetTxIdsContext(ctx context.Context, optionals ...OptionalValue) (*getTxIdsResponse, error) {
 	values := &url.Values{}
 	addOptionals(optionals, values)
 
@@ -155,7 +183,7 @@ func (c *Client) GetTxIds(optionals ...OptionalValue) (*getTxIdsResponse, error)
 		errResponse
 		Result *getTxIdsResponse `json:"result"`
 	}
-	if err := c.call("get_tx_ids", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_tx_ids", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,114 @@
+package coinpayments
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+//uriSchemes maps currencies to the scheme of their BIP21 style payment uris
+var uriSchemes = map[string]string{
+	"BTC":  "bitcoin",
+	"BCH":  "bitcoincash",
+	"LTC":  "litecoin",
+	"LTCT": "litecoin",
+	"DOGE": "dogecoin",
+	"DASH": "dash",
+	"ZEC":  "zcash",
+	"XMR":  "monero",
+}
+
+//PaymentURI returns a payment uri for sending amount of currency to address, amount and destTag may be empty
+func PaymentURI(currency, address, amount, destTag string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("coinpayments: payment uri needs an address")
+	}
+	currency = strings.ToUpper(currency)
+
+	query := url.Values{}
+	switch {
+	case currency == "ETH" || currency == "ETC":
+		if amount != "" {
+			wei, err := parseAmount(amount)
+			if err != nil {
+				return "", err
+			}
+			wei.Mul(wei, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)))
+			if !wei.IsInt() {
+				return "", fmt.Errorf("coinpayments: amount %v has more precision than wei", amount)
+			}
+			query.Set("value", wei.Num().String())
+		}
+		return buildPaymentURI("ethereum", address, query), nil
+	case strings.HasSuffix(currency, ".ERC20") || strings.HasSuffix(currency, ".BEP20"):
+		//token transfers need the contract address, which the api does not return, so only the recipient is encoded
+		return buildPaymentURI("ethereum", address, query), nil
+	case currency == "XRP":
+		if amount != "" {
+			query.Set("amount", amount)
+		}
+		if destTag != "" {
+			query.Set("dt", destTag)
+		}
+		return buildPaymentURI("ripple", address, query), nil
+	case currency == "XLM":
+		query.Set("destination", address)
+		if amount != "" {
+			query.Set("amount", amount)
+		}
+		if destTag != "" {
+			query.Set("memo", destTag)
+			query.Set("memo_type", "MEMO_ID")
+		}
+		return buildPaymentURI("web+stellar", "pay", query), nil
+	}
+
+	scheme, ok := uriSchemes[currency]
+	if !ok {
+		return "", fmt.Errorf("coinpayments: no payment uri scheme for %v", currency)
+	}
+	if amount != "" {
+		query.Set("amount", amount)
+	}
+	if destTag != "" {
+		query.Set("dt", destTag)
+	}
+	return buildPaymentURI(scheme, strings.TrimPrefix(address, scheme+":"), query), nil
+}
+
+func buildPaymentURI(scheme, path string, query url.Values) string {
+	uri := scheme + ":" + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	return uri
+}
+
+//PaymentURI returns a payment uri for the transaction, currency is the currency2 it was created with
+func (r *createTransactionResponse) PaymentURI(currency string) (string, error) {
+	return PaymentURI(currency, r.Address, r.Amount, r.DestTag)
+}
+
+//QRCode returns a locally generated QR code of the transaction's payment uri, to use instead of QRCodeURL
+func (r *createTransactionResponse) QRCode(currency string) (*QRCode, error) {
+	uri, err := r.PaymentURI(currency)
+	if err != nil {
+		return nil, err
+	}
+	return NewQRCode(uri)
+}
+
+//PaymentURI returns a payment uri without an amount for the callback address
+func (r *getCallbackAddressResponse) PaymentURI(currency string) (string, error) {
+	return PaymentURI(currency, r.Address, "", r.DestTag)
+}
+
+//QRCode returns a locally generated QR code of the callback address payment uri
+func (r *getCallbackAddressResponse) QRCode(currency string) (*QRCode, error) {
+	uri, err := r.PaymentURI(currency)
+	if err != nil {
+		return nil, err
+	}
+	return NewQRCode(uri)
+}
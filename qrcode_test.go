@@ -0,0 +1,52 @@
+package coinpayments
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//TestNewQRCodeGolden compares against matrices in testdata made by an independent encoder with the same version, level and mask, one row per line with '#' for dark modules
+func TestNewQRCodeGolden(t *testing.T) {
+	tests := []struct {
+		golden  string
+		content string
+		size    int
+	}{
+		{"qr_hi.txt", "hi", 21},
+		{"qr_uri.txt", "bitcoin:1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH?amount=0.0123", 33},
+		{"qr_version7.txt", strings.Repeat("coinpayments ", 9), 45},
+		{"qr_version10.txt", strings.Repeat("coinpayments ", 16), 57},
+	}
+
+	for _, tt := range tests {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+		q, err := NewQRCode(tt.content)
+		if err != nil {
+			t.Fatalf("%v: NewQRCode returned %v", tt.golden, err)
+		}
+		if q.Size() != tt.size || len(rows) != tt.size {
+			t.Fatalf("%v: NewQRCode size %v and golden size %v, want %v", tt.golden, q.Size(), len(rows), tt.size)
+		}
+
+		for y, row := range rows {
+			for x := 0; x < len(row); x++ {
+				if want := row[x] == '#'; q.Dark(x, y) != want {
+					t.Errorf("%v: module (%v, %v) dark is %v, want %v", tt.golden, x, y, q.Dark(x, y), want)
+				}
+			}
+		}
+	}
+}
+
+func TestNewQRCodeTooLong(t *testing.T) {
+	if _, err := NewQRCode(strings.Repeat("x", 2332)); err == nil {
+		t.Error("NewQRCode returned nil error for content beyond version 40")
+	}
+}
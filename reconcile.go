@@ -0,0 +1,224 @@
+package coinpayments
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+//LedgerRecord is a payment or withdrawal as recorded locally
+type LedgerRecord struct {
+	ID       string
+	Currency string
+	Amount   string
+	//Status is the last coinpayments status code recorded for the entry
+	Status int
+}
+
+//LedgerSource supplies local records to a Reconciler
+type LedgerSource interface {
+	Payments(since, until time.Time) ([]LedgerRecord, error)
+	Withdrawals(since, until time.Time) ([]LedgerRecord, error)
+}
+
+//ReconciliationProblem describes how a local and remote entry disagree
+type ReconciliationProblem string
+
+//Reconciliation problems
+const (
+	//ReconcileMissing means coinpayments has an entry the local ledger does not
+	ReconcileMissing ReconciliationProblem = "missing"
+	//ReconcileExtra means the local ledger has an entry coinpayments does not
+	ReconcileExtra          ReconciliationProblem = "extra"
+	ReconcileAmountMismatch ReconciliationProblem = "amount_mismatch"
+	ReconcileStatusMismatch ReconciliationProblem = "status_mismatch"
+)
+
+//ReconciliationIssue is a single disagreement found by a Reconciler
+type ReconciliationIssue struct {
+	Kind         string                `json:"kind"`
+	ID           string                `json:"id"`
+	Problem      ReconciliationProblem `json:"problem"`
+	Currency     string                `json:"currency"`
+	LocalAmount  string                `json:"local_amount,omitempty"`
+	RemoteAmount string                `json:"remote_amount,omitempty"`
+	LocalStatus  string                `json:"local_status,omitempty"`
+	RemoteStatus string                `json:"remote_status,omitempty"`
+}
+
+//ReconciliationReport is the result of reconciling a time window
+type ReconciliationReport struct {
+	Since       time.Time             `json:"since"`
+	Until       time.Time             `json:"until"`
+	Payments    int                   `json:"payments"`
+	Withdrawals int                   `json:"withdrawals"`
+	Issues      []ReconciliationIssue `json:"issues"`
+}
+
+//WriteJSON writes the report as indented JSON
+func (r *ReconciliationReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("coinpayments: error writing reconciliation report - %v", err)
+	}
+	return nil
+}
+
+//WriteCSV writes the issues of the report as CSV with a header row
+func (r *ReconciliationReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"kind", "id", "problem", "currency", "local_amount", "remote_amount", "local_status", "remote_status"}}
+	for _, i := range r.Issues {
+		rows = append(rows, []string{i.Kind, i.ID, string(i.Problem), i.Currency, i.LocalAmount, i.RemoteAmount, i.LocalStatus, i.RemoteStatus})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("coinpayments: error writing reconciliation report - %v", err)
+	}
+	return nil
+}
+
+//Reconciler compares coinpayments history with a local ledger
+type Reconciler struct {
+	client *Client
+	source LedgerSource
+}
+
+//NewReconciler returns a Reconciler comparing the client's history with the source
+func NewReconciler(client *Client, source LedgerSource) *Reconciler {
+	return &Reconciler{client: client, source: source}
+}
+
+//remoteEntry is a coinpayments payment or withdrawal reduced to the fields that are compared
+type remoteEntry struct {
+	currency string
+	amount   string
+	status   int
+}
+
+//Reconcile compares payments and withdrawals created between since and until
+func (r *Reconciler) Reconcile(since, until time.Time) (*ReconciliationReport, error) {
+	return r.ReconcileContext(context.Background(), since, until)
+}
+
+//ReconcileContext compares payments and withdrawals created between since and until with the context
+func (r *Reconciler) ReconcileContext(ctx context.Context, since, until time.Time) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{Since: since, Until: until}
+
+	history, err := r.client.paymentHistory(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+	local, err := r.source.Payments(since, until)
+	if err != nil {
+		return nil, err
+	}
+	payments := make(map[string]remoteEntry, len(history))
+	for _, h := range history {
+		payments[h.ID] = remoteEntry{currency: h.Coin, amount: h.Amount, status: h.Status}
+	}
+	report.Payments = len(payments)
+	report.Issues = append(report.Issues, compareLedger("payment", local, payments)...)
+
+	sent, err := r.client.withdrawalHistory(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+	if local, err = r.source.Withdrawals(since, until); err != nil {
+		return nil, err
+	}
+	withdrawals := make(map[string]remoteEntry, len(sent))
+	for _, h := range sent {
+		withdrawals[h.ID] = remoteEntry{currency: h.Coin, amount: h.Amount, status: h.Status}
+	}
+	report.Withdrawals = len(withdrawals)
+	report.Issues = append(report.Issues, compareLedger("withdrawal", local, withdrawals)...)
+
+	return report, nil
+}
+
+//statusCategory reduces a status code to the distinction a ledger must agree on
+func statusCategory(status int) string {
+	switch {
+	case status < 0:
+		return "cancelled"
+	case paymentComplete(status):
+		return "complete"
+	default:
+		return "pending"
+	}
+}
+
+func compareLedger(kind string, local []LedgerRecord, remote map[string]remoteEntry) []ReconciliationIssue {
+	var issues []ReconciliationIssue
+	seen := make(map[string]bool, len(local))
+
+	for _, l := range local {
+		seen[l.ID] = true
+		rem, ok := remote[l.ID]
+		if !ok {
+			issues = append(issues, ReconciliationIssue{
+				Kind:        kind,
+				ID:          l.ID,
+				Problem:     ReconcileExtra,
+				Currency:    l.Currency,
+				LocalAmount: l.Amount,
+				LocalStatus: statusCategory(l.Status),
+			})
+			continue
+		}
+
+		issue := ReconciliationIssue{
+			Kind:         kind,
+			ID:           l.ID,
+			Currency:     rem.currency,
+			LocalAmount:  l.Amount,
+			RemoteAmount: rem.amount,
+			LocalStatus:  statusCategory(l.Status),
+			RemoteStatus: statusCategory(rem.status),
+		}
+		if !amountsEqual(l.Amount, rem.amount) || !strings.EqualFold(l.Currency, rem.currency) {
+			issue.Problem = ReconcileAmountMismatch
+			issues = append(issues, issue)
+		}
+		if issue.LocalStatus != issue.RemoteStatus {
+			issue.Problem = ReconcileStatusMismatch
+			issues = append(issues, issue)
+		}
+	}
+
+	var missing []string
+	for id := range remote {
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	for _, id := range missing {
+		rem := remote[id]
+		issues = append(issues, ReconciliationIssue{
+			Kind:         kind,
+			ID:           id,
+			Problem:      ReconcileMissing,
+			Currency:     rem.currency,
+			RemoteAmount: rem.amount,
+			RemoteStatus: statusCategory(rem.status),
+		})
+	}
+	return issues
+}
+
+//amountsEqual compares two decimal amounts numerically, falling back to string comparison
+func amountsEqual(a, b string) bool {
+	x, err1 := parseAmount(a)
+	y, err2 := parseAmount(b)
+	if err1 != nil || err2 != nil {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
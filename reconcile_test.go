@@ -0,0 +1,64 @@
+package coinpayments
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type staticLedgerSource struct {
+	payments, withdrawals []LedgerRecord
+}
+
+func (s staticLedgerSource) Payments(since, until time.Time) ([]LedgerRecord, error) {
+	return s.payments, nil
+}
+
+func (s staticLedgerSource) Withdrawals(since, until time.Time) ([]LedgerRecord, error) {
+	return s.withdrawals, nil
+}
+
+func TestReconcileContext(t *testing.T) {
+	since := time.Unix(1600000000, 0)
+	until := since.Add(24 * time.Hour)
+	created := strconv.FormatInt(since.Add(time.Hour).Unix(), 10)
+
+	api := newFakeAPI()
+	api.set("get_tx_ids", `["T1","T2","T3"]`)
+	api.set("get_tx_info_multi", `{
+		"T1":{"error":"ok","time_created":`+created+`,"status":100,"coin":"BTC","amountf":"1"},
+		"T2":{"error":"ok","time_created":`+created+`,"status":1,"coin":"BTC","amountf":"2"},
+		"T3":{"error":"ok","time_created":1,"status":100,"coin":"BTC","amountf":"3"}}`)
+	api.set("get_withdrawal_history", `[{"id":"W1","time_created":`+created+`,"status":2,"coin":"LTC","amountf":"5"}]`)
+
+	source := staticLedgerSource{
+		payments: []LedgerRecord{
+			{ID: "T1", Currency: "BTC", Amount: "1.0", Status: 100},
+			{ID: "T2", Currency: "BTC", Amount: "2", Status: 100},
+			{ID: "T9", Currency: "BTC", Amount: "9", Status: 0},
+		},
+		withdrawals: []LedgerRecord{{ID: "W1", Currency: "LTC", Amount: "4", Status: 2}},
+	}
+
+	report, err := NewReconciler(api.client(), source).ReconcileContext(context.Background(), since, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ReconciliationIssue{
+		{Kind: "payment", ID: "T2", Problem: ReconcileStatusMismatch, Currency: "BTC", LocalAmount: "2", RemoteAmount: "2", LocalStatus: "complete", RemoteStatus: "pending"},
+		{Kind: "payment", ID: "T9", Problem: ReconcileExtra, Currency: "BTC", LocalAmount: "9", LocalStatus: "pending"},
+		{Kind: "withdrawal", ID: "W1", Problem: ReconcileAmountMismatch, Currency: "LTC", LocalAmount: "4", RemoteAmount: "5", LocalStatus: "complete", RemoteStatus: "complete"},
+	}
+	if report.Payments != 2 || report.Withdrawals != 1 || !reflect.DeepEqual(report.Issues, want) {
+		t.Errorf("ReconcileContext reported %v payments, %v withdrawals and issues %+v, want 2, 1 and %+v", report.Payments, report.Withdrawals, report.Issues, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewReconciler(api.client(), source).ReconcileContext(ctx, since, until); err == nil {
+		t.Error("ReconcileContext with a cancelled context returned nil error")
+	}
+}
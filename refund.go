@@ -0,0 +1,295 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+//RefundRequest asks for funds from a payment to be sent back to the buyer
+type RefundRequest struct {
+	TxnID string
+	//Amount to refund, or empty to refund everything still refundable
+	Amount string
+	//Fee is the coinpayments fee charged on the payment, such as the fee field of its IPN, and may only be empty if WithRefundFeeRate is used
+	Fee     string
+	Address string
+	DestTag string
+	PBNTag  string
+}
+
+//Refund is the record of a refund sent for a payment
+type Refund struct {
+	ID           string
+	TxnID        string
+	Currency     string
+	Amount       string
+	Address      string
+	DestTag      string
+	PBNTag       string
+	Note         string
+	WithdrawalID string
+	//Status is the withdrawal status, refreshed with "get_withdrawal_info" until it completes or fails
+	Status    int
+	CreatedAt time.Time
+}
+
+//RefundStore persists refunds for a RefundManager
+type RefundStore interface {
+	SaveRefund(refund *Refund) error
+	RefundsForTxn(txnID string) ([]*Refund, error)
+}
+
+//MemoryRefundStore is a RefundStore that keeps refunds in memory
+type MemoryRefundStore struct {
+	mu      sync.Mutex
+	refunds map[string][]Refund
+}
+
+//NewMemoryRefundStore returns an empty MemoryRefundStore
+func NewMemoryRefundStore() *MemoryRefundStore {
+	return &MemoryRefundStore{refunds: make(map[string][]Refund)}
+}
+
+//SaveRefund stores a copy of the refund
+func (s *MemoryRefundStore) SaveRefund(refund *Refund) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refunds := s.refunds[refund.TxnID]
+	for i := range refunds {
+		if refunds[i].ID == refund.ID {
+			refunds[i] = *refund
+			return nil
+		}
+	}
+	s.refunds[refund.TxnID] = append(refunds, *refund)
+	return nil
+}
+
+//RefundsForTxn returns copies of the refunds sent for a payment
+func (s *MemoryRefundStore) RefundsForTxn(txnID string) ([]*Refund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*Refund
+	for _, r := range s.refunds[txnID] {
+		r := r
+		list = append(list, &r)
+	}
+	return list, nil
+}
+
+//RefundManagerOption is an option used to modify a RefundManager
+type RefundManagerOption func(manager *RefundManager)
+
+//RefundManager sends refunds for payments and links them to the original transaction
+type RefundManager struct {
+	client  *Client
+	store   RefundStore
+	feeRate *big.Rat
+
+	mu sync.Mutex
+}
+
+//NewRefundManager returns a new RefundManager persisting to store with the applied options
+func NewRefundManager(client *Client, store RefundStore, options ...RefundManagerOption) *RefundManager {
+	manager := &RefundManager{
+		client: client,
+		store:  store,
+	}
+
+	for _, o := range options {
+		o(manager)
+	}
+	return manager
+}
+
+//WithRefundFeeRate is an option that estimates the coinpayments fee, as a fraction such as "0.005", for refunds that do not give the payment's fee
+func WithRefundFeeRate(rate string) RefundManagerOption {
+	return func(manager *RefundManager) {
+		if r, err := parseAmount(rate); err == nil {
+			manager.feeRate = r
+		}
+	}
+}
+
+//Refundable returns the amount and currency of a payment that can still be refunded, net of the fee and earlier refunds,
+//fee is the payment's coinpayments fee or empty to estimate it with the WithRefundFeeRate rate
+func (m *RefundManager) Refundable(txnID, fee string) (string, string, error) {
+	return m.RefundableContext(context.Background(), txnID, fee)
+}
+
+//RefundableContext returns the amount and currency of a payment that can still be refunded with the context
+func (m *RefundManager) RefundableContext(ctx context.Context, txnID, fee string) (string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.client.GetTxInfoContext(ctx, txnID)
+	if err != nil {
+		return "", "", err
+	}
+
+	amount, err := m.refundable(ctx, txnID, fee, info)
+	if err != nil {
+		return "", "", err
+	}
+	return formatAmount(amount), info.Coin, nil
+}
+
+//refundable returns what is left of a payment after its fee and the refunds that have not failed, the caller must hold m.mu
+func (m *RefundManager) refundable(ctx context.Context, txnID, fee string, info *getTxInfoResponse) (*big.Rat, error) {
+	if info.Status >= 0 && !paymentComplete(info.Status) {
+		return nil, fmt.Errorf("coinpayments: payment %v is still pending", txnID)
+	}
+
+	received := new(big.Rat)
+	if info.Receivedf != "" {
+		r, err := parseAmount(info.Receivedf)
+		if err != nil {
+			return nil, err
+		}
+		received = r
+	}
+
+	var paid *big.Rat
+	switch {
+	case fee != "":
+		f, err := parseAmount(fee)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: invalid fee for payment %v - %v", txnID, err)
+		}
+		paid = f
+	case m.feeRate != nil:
+		paid = new(big.Rat).Mul(received, m.feeRate)
+	default:
+		return nil, fmt.Errorf("coinpayments: fee of payment %v is unknown, set the refund fee or use WithRefundFeeRate", txnID)
+	}
+	refundable := new(big.Rat).Sub(received, paid)
+
+	refunds, err := m.store.RefundsForTxn(txnID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range refunds {
+		if err := m.refresh(ctx, r); err != nil {
+			return nil, err
+		}
+		//a failed or cancelled withdrawal sent nothing, so its amount is refundable again
+		if r.Status < 0 {
+			continue
+		}
+		a, err := parseAmount(r.Amount)
+		if err != nil {
+			return nil, err
+		}
+		refundable.Sub(refundable, a)
+	}
+
+	if refundable.Sign() < 0 {
+		refundable.SetInt64(0)
+	}
+	return refundable, nil
+}
+
+//refresh updates the status of a refund whose withdrawal has not completed or failed yet
+func (m *RefundManager) refresh(ctx context.Context, refund *Refund) error {
+	if refund.Status < 0 || refund.Status == 2 || refund.WithdrawalID == "" {
+		return nil
+	}
+
+	info, err := m.client.GetWithdrawalInfoContext(ctx, refund.WithdrawalID)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error refreshing refund %v - %v", refund.ID, err)
+	}
+	if info.Status == refund.Status {
+		return nil
+	}
+	refund.Status = info.Status
+	return m.store.SaveRefund(refund)
+}
+
+//Refund withdraws funds from a payment back to the buyer with a note naming the original transaction
+func (m *RefundManager) Refund(req RefundRequest, optionals ...OptionalValue) (*Refund, error) {
+	return m.RefundContext(context.Background(), req, optionals...)
+}
+
+//RefundContext withdraws funds from a payment back to the buyer with the context
+func (m *RefundManager) RefundContext(ctx context.Context, req RefundRequest, optionals ...OptionalValue) (*Refund, error) {
+	if (req.Address == "") == (req.PBNTag == "") {
+		return nil, fmt.Errorf("coinpayments: refund needs exactly one of address or pbntag")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.client.GetTxInfoContext(ctx, req.TxnID)
+	if err != nil {
+		return nil, err
+	}
+
+	refundable, err := m.refundable(ctx, req.TxnID, req.Fee, info)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := refundable
+	if req.Amount != "" {
+		if amount, err = parseAmount(req.Amount); err != nil {
+			return nil, err
+		}
+		if amount.Cmp(refundable) > 0 {
+			return nil, fmt.Errorf("coinpayments: refund of %v exceeds refundable %v %v", req.Amount, formatAmount(refundable), info.Coin)
+		}
+	}
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("coinpayments: nothing to refund for payment %v", req.TxnID)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &Refund{
+		ID:        id,
+		TxnID:     req.TxnID,
+		Currency:  strings.ToUpper(info.Coin),
+		Amount:    formatAmount(amount),
+		Address:   req.Address,
+		DestTag:   req.DestTag,
+		PBNTag:    req.PBNTag,
+		Note:      fmt.Sprintf("refund %v for %v", id, req.TxnID),
+		CreatedAt: time.Now(),
+	}
+
+	optionals = append(optionals, WithOptionalValue("note", refund.Note))
+	if req.Address != "" {
+		optionals = append(optionals, WithOptionalValue("address", req.Address))
+	} else {
+		optionals = append(optionals, WithOptionalValue("pbntag", req.PBNTag))
+	}
+	if req.DestTag != "" {
+		optionals = append(optionals, WithOptionalValue("dest_tag", req.DestTag))
+	}
+
+	resp, err := m.client.CreateWithdrawalContext(ctx, refund.Amount, refund.Currency, optionals...)
+	if err != nil {
+		return nil, err
+	}
+	refund.WithdrawalID = resp.ID
+	refund.Status = resp.Status
+
+	if err := m.store.SaveRefund(refund); err != nil {
+		return refund, fmt.Errorf("coinpayments: refund %v sent as withdrawal %v but not stored - %v", refund.ID, refund.WithdrawalID, err)
+	}
+	return refund, nil
+}
+
+//Refunds returns the refunds sent for a payment
+func (m *RefundManager) Refunds(txnID string) ([]*Refund, error) {
+	return m.store.RefundsForTxn(txnID)
+}
@@ -0,0 +1,79 @@
+package coinpayments
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRefundable(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    string
+		fee     string
+		options []RefundManagerOption
+		want    string
+		wantErr bool
+	}{
+		{name: "fee given", info: `{"status":100,"coin":"BTC","receivedf":"1"}`, fee: "0.01", want: "0.99"},
+		{name: "fee rate", info: `{"status":100,"coin":"BTC","receivedf":"1"}`, options: []RefundManagerOption{WithRefundFeeRate("0.005")}, want: "0.995"},
+		{name: "cancelled payment", info: `{"status":-1,"coin":"BTC","receivedf":"0.5"}`, fee: "0", want: "0.5"},
+		{name: "fee unknown", info: `{"status":100,"coin":"BTC","receivedf":"1"}`, wantErr: true},
+		{name: "pending", info: `{"status":1,"coin":"BTC","receivedf":"1"}`, fee: "0.01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		api := newFakeAPI()
+		api.set("get_tx_info", tt.info)
+		manager := NewRefundManager(api.client(), NewMemoryRefundStore(), tt.options...)
+
+		amount, coin, err := manager.Refundable("T1", tt.fee)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: Refundable returned nil error", tt.name)
+			}
+			continue
+		}
+		if err != nil || amount != tt.want || coin != "BTC" {
+			t.Errorf("%v: Refundable returned %v %v, %v, want %v BTC", tt.name, amount, coin, err, tt.want)
+		}
+	}
+}
+
+func TestRefundSkipsFailedWithdrawals(t *testing.T) {
+	api := newFakeAPI()
+	api.set("get_tx_info", `{"status":100,"coin":"BTC","receivedf":"1"}`)
+	api.set("create_withdrawal", `{"id":"W1","status":0,"amount":"0.6"}`)
+	manager := NewRefundManager(api.client(), NewMemoryRefundStore())
+
+	refund, err := manager.RefundContext(context.Background(), RefundRequest{TxnID: "T1", Amount: "0.6", Fee: "0", Address: "addr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := api.calls[1]
+	if values.Get("amount") != "0.6" || values.Get("currency") != "BTC" || values.Get("note") != refund.Note || values.Get("address") != "addr" {
+		t.Errorf("create_withdrawal sent %v, want 0.6 BTC to addr with the refund note", values)
+	}
+
+	api.set("get_withdrawal_info", `{"status":0}`)
+	if amount, _, err := manager.Refundable("T1", "0"); err != nil || amount != "0.4" {
+		t.Fatalf("with the refund pending Refundable returned %v, %v, want 0.4", amount, err)
+	}
+	if _, err := manager.Refund(RefundRequest{TxnID: "T1", Amount: "0.5", Fee: "0", Address: "addr"}); err == nil {
+		t.Fatal("Refund beyond the refundable amount returned nil error")
+	}
+
+	api.set("get_withdrawal_info", `{"status":-1}`)
+	if amount, _, err := manager.Refundable("T1", "0"); err != nil || amount != "1" {
+		t.Fatalf("with the refund cancelled Refundable returned %v, %v, want 1", amount, err)
+	}
+	refunds, _ := manager.Refunds("T1")
+	if len(refunds) != 1 || refunds[0].Status != -1 {
+		t.Fatalf("Refunds returned %+v, want the refund stored as cancelled", refunds)
+	}
+
+	//a failed refund is not looked up again
+	api.fail("get_withdrawal_info", "unavailable")
+	if amount, _, err := manager.Refundable("T1", "0"); err != nil || amount != "1" {
+		t.Errorf("Refundable returned %v, %v after the refund failed, want 1", amount, err)
+	}
+}
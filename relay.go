@@ -0,0 +1,449 @@
+package coinpayments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//RelayEvent is the JSON body delivered to internal webhooks for a verified IPN
+type RelayEvent struct {
+	ID         string            `json:"id"`
+	IPNID      string            `json:"ipn_id"`
+	IPNType    string            `json:"ipn_type"`
+	Fields     map[string]string `json:"fields"`
+	ReceivedAt time.Time         `json:"received_at"`
+}
+
+//RelayMessage is a pending delivery of an event to one target
+type RelayMessage struct {
+	ID          string          `json:"id"`
+	Target      string          `json:"target"`
+	Body        json.RawMessage `json:"body"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+//RelayQueue durably stores relay messages between delivery attempts
+type RelayQueue interface {
+	Put(msg *RelayMessage) error
+	Pending() ([]*RelayMessage, error)
+	Delete(id string) error
+	PutDead(msg *RelayMessage) error
+	Dead() ([]*RelayMessage, error)
+	DeleteDead(id string) error
+}
+
+//FileRelayQueue is a RelayQueue keeping one JSON file per message in a directory
+type FileRelayQueue struct {
+	pending string
+	dead    string
+}
+
+//NewFileRelayQueue returns a FileRelayQueue storing messages under dir, creating it if needed
+func NewFileRelayQueue(dir string) (*FileRelayQueue, error) {
+	q := &FileRelayQueue{
+		pending: filepath.Join(dir, "pending"),
+		dead:    filepath.Join(dir, "dead"),
+	}
+	for _, d := range []string{q.pending, q.dead} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return nil, fmt.Errorf("coinpayments: error creating relay queue - %v", err)
+		}
+	}
+	return q, nil
+}
+
+//Put writes a pending message, replacing any earlier version of it
+func (q *FileRelayQueue) Put(msg *RelayMessage) error {
+	return writeMessage(q.pending, msg)
+}
+
+//Pending returns every pending message, oldest first
+func (q *FileRelayQueue) Pending() ([]*RelayMessage, error) {
+	return readMessages(q.pending)
+}
+
+//Delete removes a pending message
+func (q *FileRelayQueue) Delete(id string) error {
+	return removeMessage(q.pending, id)
+}
+
+//PutDead writes a dead lettered message
+func (q *FileRelayQueue) PutDead(msg *RelayMessage) error {
+	return writeMessage(q.dead, msg)
+}
+
+//Dead returns every dead lettered message, oldest first
+func (q *FileRelayQueue) Dead() ([]*RelayMessage, error) {
+	return readMessages(q.dead)
+}
+
+//DeleteDead removes a dead lettered message
+func (q *FileRelayQueue) DeleteDead(id string) error {
+	return removeMessage(q.dead, id)
+}
+
+func writeMessage(dir string, msg *RelayMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error marshaling relay message - %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("coinpayments: error writing relay message - %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("coinpayments: error writing relay message - %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("coinpayments: error writing relay message - %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("coinpayments: error writing relay message - %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, msg.ID+".json")); err != nil {
+		return fmt.Errorf("coinpayments: error writing relay message - %v", err)
+	}
+	return nil
+}
+
+func readMessages(dir string) ([]*RelayMessage, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("coinpayments: error reading relay queue - %v", err)
+	}
+
+	var messages []*RelayMessage
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error reading relay message - %v", err)
+		}
+		var msg RelayMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("coinpayments: error unmarshaling relay message %v - %v", f.Name(), err)
+		}
+		messages = append(messages, &msg)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+func removeMessage(dir, id string) error {
+	if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("coinpayments: error removing relay message - %v", err)
+	}
+	return nil
+}
+
+//IPNRelayOption is an option used to modify an IPNRelay
+type IPNRelayOption func(relay *IPNRelay)
+
+//IPNRelay verifies IPNs, queues them durably and forwards them to internal webhooks with retries
+type IPNRelay struct {
+	client      *Client
+	queue       RelayQueue
+	httpClient  *http.Client
+	targets     []string
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxAttempts int
+	onError     ErrorFunc
+
+	mu sync.Mutex
+}
+
+//NewIPNRelay returns a new IPNRelay verifying with the client and queueing to queue, with the applied options,
+//the client must have an IPN secret so unverified IPNs are never forwarded
+func NewIPNRelay(client *Client, queue RelayQueue, options ...IPNRelayOption) (*IPNRelay, error) {
+	if client.ipnSecret == "" {
+		return nil, fmt.Errorf("coinpayments: IPN relay requires a client with an IPN secret")
+	}
+
+	relay := &IPNRelay{
+		client:      client,
+		queue:       queue,
+		httpClient:  http.DefaultClient,
+		baseDelay:   10 * time.Second,
+		maxDelay:    time.Hour,
+		maxAttempts: 20,
+	}
+
+	for _, o := range options {
+		o(relay)
+	}
+	return relay, nil
+}
+
+//WithRelayTarget is an option that forwards every IPN to the webhook url
+func WithRelayTarget(target string) IPNRelayOption {
+	return func(relay *IPNRelay) {
+		relay.targets = append(relay.targets, target)
+	}
+}
+
+//WithRelayRetry is an option that sets the exponential backoff between deliveries and when messages are dead lettered
+func WithRelayRetry(baseDelay, maxDelay time.Duration, maxAttempts int) IPNRelayOption {
+	return func(relay *IPNRelay) {
+		relay.baseDelay = baseDelay
+		relay.maxDelay = maxDelay
+		relay.maxAttempts = maxAttempts
+	}
+}
+
+//WithRelayHTTPClient is an option that makes the IPNRelay deliver with the provided http client
+func WithRelayHTTPClient(httpClient *http.Client) IPNRelayOption {
+	return func(relay *IPNRelay) {
+		relay.httpClient = httpClient
+	}
+}
+
+//WithRelayErrorFunc is an option that receives the errors Run handles without stopping
+func WithRelayErrorFunc(fn ErrorFunc) IPNRelayOption {
+	return func(relay *IPNRelay) {
+		relay.onError = fn
+	}
+}
+
+//ServeHTTP verifies the IPN, queues it for every target and acknowledges it
+func (r *IPNRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "coinpayments: error reading request body", http.StatusBadRequest)
+		return
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	ipn, err := r.client.ParseIPN(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := r.Enqueue(ipn, body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("IPN OK"))
+}
+
+//Enqueue queues a verified IPN and its raw body for delivery to every target
+func (r *IPNRelay) Enqueue(ipn *IPN, rawBody []byte) error {
+	if len(r.targets) == 0 {
+		return fmt.Errorf("coinpayments: IPN relay has no targets")
+	}
+
+	values, err := url.ParseQuery(string(rawBody))
+	if err != nil {
+		return fmt.Errorf("coinpayments: error parsing IPN body - %v", err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	event := RelayEvent{
+		ID:         id,
+		IPNID:      ipn.IPNId,
+		IPNType:    ipn.IPNType,
+		Fields:     make(map[string]string, len(values)),
+		ReceivedAt: now,
+	}
+	for k := range values {
+		event.Fields[k] = values.Get(k)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error marshaling relay event - %v", err)
+	}
+
+	for i, target := range r.targets {
+		msg := &RelayMessage{
+			ID:          fmt.Sprintf("%v-%v", id, i),
+			Target:      target,
+			Body:        body,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}
+		if err := r.queue.Put(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Deliver attempts every due message once, rescheduling failures and dead lettering exhausted ones,
+//a message the queue fails to update does not stop the others and its error is returned in WorkerErrors
+func (r *IPNRelay) Deliver() error {
+	return r.DeliverContext(context.Background())
+}
+
+//DeliverContext attempts every due message once with the context, rescheduling failures and dead lettering exhausted ones
+func (r *IPNRelay) DeliverContext(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages, err := r.queue.Pending()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var errs WorkerErrors
+	for _, msg := range messages {
+		if msg.NextAttempt.After(now) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return appendErrors(errs, err)
+		}
+		err := r.deliver(ctx, msg, now)
+		switch {
+		case err == nil:
+		case err == ctx.Err():
+			return appendErrors(errs, err)
+		default:
+			errs = append(errs, fmt.Errorf("coinpayments: error updating relay message %v - %v", msg.ID, err))
+		}
+	}
+	return errs.err()
+}
+
+//deliver attempts a message and moves it to where its result belongs in the queue, the caller must hold r.mu,
+//an attempt cut short by the caller's context leaves the message as it was and returns the context's error
+func (r *IPNRelay) deliver(ctx context.Context, msg *RelayMessage, now time.Time) error {
+	err := r.post(ctx, msg)
+	if err == nil {
+		return r.queue.Delete(msg.ID)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	msg.Attempts++
+	msg.LastError = err.Error()
+	if msg.Attempts >= r.maxAttempts {
+		if err := r.queue.PutDead(msg); err != nil {
+			return err
+		}
+		return r.queue.Delete(msg.ID)
+	}
+
+	msg.NextAttempt = now.Add(r.backoff(msg.Attempts))
+	return r.queue.Put(msg)
+}
+
+//Run delivers due messages on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (r *IPNRelay) Run(ctx context.Context, interval time.Duration) error {
+	return r.client.runEvery(ctx, interval, r.DeliverContext, r.onError)
+}
+
+//DeadLetters returns the messages that exhausted their delivery attempts
+func (r *IPNRelay) DeadLetters() ([]*RelayMessage, error) {
+	return r.queue.Dead()
+}
+
+//Redeliver moves a dead lettered message back to the pending queue with its attempts reset
+func (r *IPNRelay) Redeliver(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dead, err := r.queue.Dead()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range dead {
+		if msg.ID == id {
+			return r.redeliver(msg)
+		}
+	}
+	return fmt.Errorf("coinpayments: no dead lettered message %v", id)
+}
+
+//RedeliverAll moves every dead lettered message back to the pending queue
+func (r *IPNRelay) RedeliverAll() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dead, err := r.queue.Dead()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range dead {
+		if err := r.redeliver(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//redeliver resets a dead lettered message and moves it to the pending queue, the caller must hold r.mu
+func (r *IPNRelay) redeliver(msg *RelayMessage) error {
+	msg.Attempts = 0
+	msg.LastError = ""
+	msg.NextAttempt = time.Now()
+	if err := r.queue.Put(msg); err != nil {
+		return err
+	}
+	return r.queue.DeleteDead(msg.ID)
+}
+
+func (r *IPNRelay) post(ctx context.Context, msg *RelayMessage) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", msg.Target, bytes.NewReader(msg.Body))
+	if err != nil {
+		return fmt.Errorf("coinpayments: error creating relay request - %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error delivering relay message - %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("coinpayments: relay target returned unexpected status: %v", resp.StatusCode)
+	}
+	return nil
+}
+
+func (r *IPNRelay) backoff(attempts int) time.Duration {
+	delay := r.baseDelay
+	for i := 1; i < attempts && delay < r.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.maxDelay {
+		delay = r.maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,142 @@
+package coinpayments
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestRelay(t *testing.T, target string, options ...IPNRelayOption) (*IPNRelay, *FileRelayQueue) {
+	dir, err := ioutil.TempDir("", "relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	queue, err := NewFileRelayQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = append([]IPNRelayOption{WithRelayTarget(target)}, options...)
+	relay, err := NewIPNRelay(NewClient("", "", WithIPNSecret("secret")), queue, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := relay.Enqueue(newTestIPN(t, "ipn_type", "api", "txn_id", "T1"), []byte("ipn_type=api&txn_id=T1")); err != nil {
+		t.Fatal(err)
+	}
+	return relay, queue
+}
+
+//dueNow makes every pending message due so Deliver attempts it again
+func dueNow(t *testing.T, queue RelayQueue) {
+	pending, err := queue.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range pending {
+		msg.NextAttempt = time.Now().Add(-time.Second)
+		if err := queue.Put(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := &IPNRelay{baseDelay: time.Second, maxDelay: 10 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := relay.backoff(i + 1); got != w {
+			t.Errorf("backoff(%v) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRelayDeadLetters(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	relay, queue := newTestRelay(t, server.URL, WithRelayRetry(time.Minute, time.Hour, 3))
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		if err := relay.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		pending, _ := queue.Pending()
+		if attempt < 3 {
+			if len(pending) != 1 || pending[0].Attempts != attempt || pending[0].NextAttempt.Before(before.Add(relay.backoff(attempt))) {
+				t.Fatalf("after attempt %v the pending messages are %+v, want one rescheduled by the backoff", attempt, pending)
+			}
+			if err := relay.Deliver(); err != nil {
+				t.Fatal(err)
+			}
+			if pending, _ = queue.Pending(); pending[0].Attempts != attempt {
+				t.Fatalf("Deliver attempted a message before its next attempt")
+			}
+			dueNow(t, queue)
+		} else if len(pending) != 0 {
+			t.Fatalf("after %v attempts %v messages are pending, want none", attempt, len(pending))
+		}
+	}
+
+	dead, err := relay.DeadLetters()
+	if err != nil || len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Fatalf("DeadLetters returned %+v, %v, want one message with 3 attempts", dead, err)
+	}
+
+	status = http.StatusOK
+	if err := relay.RedeliverAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := relay.Deliver(); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := queue.Pending()
+	dead, _ = relay.DeadLetters()
+	if len(pending) != 0 || len(dead) != 0 {
+		t.Errorf("after redelivery %v messages are pending and %v dead, want none", len(pending), len(dead))
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRelayCancelledDeliveryIsNotAnAttempt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})}
+
+	relay, queue := newTestRelay(t, "http://relay.invalid", WithRelayHTTPClient(httpClient), WithRelayRetry(time.Minute, time.Hour, 1))
+	if err := relay.DeliverContext(ctx); !isCanceled(err) {
+		t.Fatalf("DeliverContext returned %v, want %v", err, context.Canceled)
+	}
+
+	pending, _ := queue.Pending()
+	dead, _ := relay.DeadLetters()
+	if len(pending) != 1 || pending[0].Attempts != 0 || pending[0].LastError != "" || pending[0].NextAttempt.After(time.Now()) || len(dead) != 0 {
+		t.Errorf("after a cancelled delivery the pending messages are %+v and %v are dead, want one untouched message", pending, len(dead))
+	}
+
+	if err := relay.DeliverContext(ctx); !isCanceled(err) {
+		t.Errorf("DeliverContext with a done context returned %v, want %v", err, context.Canceled)
+	}
+}
+
+func isCanceled(err error) bool {
+	errs, ok := err.(WorkerErrors)
+	return ok && len(errs) == 1 && errs[0] == context.Canceled
+}
@@ -0,0 +1,525 @@
+package coinpayments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ErrSubscriptionNotFound is returned by a SubscriptionStore when no subscription has the requested id
+var ErrSubscriptionNotFound = errors.New("coinpayments: subscription not found")
+
+//subscriptionCustomPrefix marks the "custom" field of transactions created for subscriptions
+const subscriptionCustomPrefix = "sub:"
+
+//SubscriptionState is the billing state of a subscription
+type SubscriptionState string
+
+//Subscription states
+const (
+	SubscriptionActive    SubscriptionState = "active"
+	SubscriptionPastDue   SubscriptionState = "past_due"
+	SubscriptionCancelled SubscriptionState = "cancelled"
+)
+
+//Plan is a recurring price billed every Interval
+type Plan struct {
+	ID       string
+	Price    string
+	Currency string
+	Interval time.Duration
+	//Lead is how long before the period ends the next cycle is billed
+	Lead time.Duration
+	//GracePeriod is how long an unpaid subscription stays past due before it is cancelled
+	GracePeriod time.Duration
+	//DunningInterval is how often a past due cycle is billed again with a fresh transaction
+	DunningInterval time.Duration
+}
+
+//validate rejects plans that could not be billed or would bill the same period forever
+func (p Plan) validate() error {
+	if p.Interval <= 0 {
+		return fmt.Errorf("coinpayments: plan %v needs a positive interval", p.ID)
+	}
+	if p.Price == "" || p.Currency == "" {
+		return fmt.Errorf("coinpayments: plan %v needs a price and currency", p.ID)
+	}
+	if _, err := parseAmount(p.Price); err != nil {
+		return fmt.Errorf("coinpayments: plan %v has an invalid price - %v", p.ID, err)
+	}
+	return nil
+}
+
+//Subscription is a buyer's recurring payment for a plan
+type Subscription struct {
+	ID          string
+	PlanID      string
+	BuyerEmail  string
+	Coin        string
+	State       SubscriptionState
+	PaidThrough time.Time
+	Cycles      []SubscriptionCycle
+	CreatedAt   time.Time
+	CancelledAt time.Time
+}
+
+//SubscriptionCycle is the billing of a single period of a subscription
+type SubscriptionCycle struct {
+	Number      int
+	PeriodStart time.Time
+	TxnIDs      []string
+	CheckoutURL string
+	BilledAt    time.Time
+	//DueAt is the later of PeriodStart and the expiry of the cycle's first transaction, past due and grace periods count from it
+	DueAt  time.Time
+	PaidAt time.Time
+}
+
+//Paid reports whether the cycle has been paid
+func (c SubscriptionCycle) Paid() bool {
+	return !c.PaidAt.IsZero()
+}
+
+func (s *Subscription) copy() *Subscription {
+	c := *s
+	c.Cycles = make([]SubscriptionCycle, len(s.Cycles))
+	for i, cycle := range s.Cycles {
+		cycle.TxnIDs = append([]string(nil), cycle.TxnIDs...)
+		c.Cycles[i] = cycle
+	}
+	return &c
+}
+
+//SubscriptionStore persists subscriptions for a SubscriptionEngine
+type SubscriptionStore interface {
+	SaveSubscription(subscription *Subscription) error
+	//LoadSubscription returns ErrSubscriptionNotFound if no subscription has the id
+	LoadSubscription(id string) (*Subscription, error)
+	ListSubscriptions() ([]*Subscription, error)
+}
+
+//MemorySubscriptionStore is a SubscriptionStore that keeps subscriptions in memory
+type MemorySubscriptionStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription
+}
+
+//NewMemorySubscriptionStore returns an empty MemorySubscriptionStore
+func NewMemorySubscriptionStore() *MemorySubscriptionStore {
+	return &MemorySubscriptionStore{subscriptions: make(map[string]*Subscription)}
+}
+
+//SaveSubscription stores a copy of the subscription
+func (s *MemorySubscriptionStore) SaveSubscription(subscription *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions[subscription.ID] = subscription.copy()
+	return nil
+}
+
+//LoadSubscription returns a copy of the stored subscription
+func (s *MemorySubscriptionStore) LoadSubscription(id string) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.subscriptions[id]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	return subscription.copy(), nil
+}
+
+//ListSubscriptions returns copies of every stored subscription
+func (s *MemorySubscriptionStore) ListSubscriptions() ([]*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Subscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		list = append(list, subscription.copy())
+	}
+	return list, nil
+}
+
+//SubscriptionBillingFunc is called with the checkout of every cycle billed, dunning is true for repeated bills of a past due cycle
+type SubscriptionBillingFunc func(subscription Subscription, cycle SubscriptionCycle, dunning bool)
+
+//SubscriptionPaymentFunc is called with a subscription and the cycle a payment completed
+type SubscriptionPaymentFunc func(subscription Subscription, cycle SubscriptionCycle)
+
+//SubscriptionEngineOption is an option used to modify a SubscriptionEngine
+type SubscriptionEngineOption func(engine *SubscriptionEngine)
+
+//SubscriptionEngine bills subscriptions each cycle through "create_transaction" and follows their payment IPNs
+type SubscriptionEngine struct {
+	client      *Client
+	store       SubscriptionStore
+	plans       map[string]Plan
+	onBilling   []SubscriptionBillingFunc
+	onState     []func(subscription Subscription)
+	onCancelled []SubscriptionPaymentFunc
+	onError     ErrorFunc
+
+	mu      sync.Mutex
+	billing map[string]bool
+}
+
+//NewSubscriptionEngine returns a new SubscriptionEngine persisting to store with the applied options,
+//it returns an error if a plan has no price or currency or a non-positive interval
+func NewSubscriptionEngine(client *Client, store SubscriptionStore, options ...SubscriptionEngineOption) (*SubscriptionEngine, error) {
+	engine := &SubscriptionEngine{
+		client:  client,
+		store:   store,
+		plans:   make(map[string]Plan),
+		billing: make(map[string]bool),
+	}
+
+	for _, o := range options {
+		o(engine)
+	}
+
+	for _, plan := range engine.plans {
+		if err := plan.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return engine, nil
+}
+
+//WithPlan is an option that makes a plan available for subscriptions
+func WithPlan(plan Plan) SubscriptionEngineOption {
+	return func(engine *SubscriptionEngine) {
+		engine.plans[plan.ID] = plan
+	}
+}
+
+//WithSubscriptionBillingFunc is an option that registers a callback for billed cycles, such as emailing the checkout url
+func WithSubscriptionBillingFunc(fn SubscriptionBillingFunc) SubscriptionEngineOption {
+	return func(engine *SubscriptionEngine) {
+		engine.onBilling = append(engine.onBilling, fn)
+	}
+}
+
+//WithSubscriptionStateFunc is an option that registers a callback for subscription state changes
+func WithSubscriptionStateFunc(fn func(subscription Subscription)) SubscriptionEngineOption {
+	return func(engine *SubscriptionEngine) {
+		engine.onState = append(engine.onState, fn)
+	}
+}
+
+//WithSubscriptionCancelledPaymentFunc is an option that registers a callback for payments completing on cancelled subscriptions,
+//which are recorded on their cycle but do not extend or reactivate the subscription, so they can be refunded or the buyer resubscribed
+func WithSubscriptionCancelledPaymentFunc(fn SubscriptionPaymentFunc) SubscriptionEngineOption {
+	return func(engine *SubscriptionEngine) {
+		engine.onCancelled = append(engine.onCancelled, fn)
+	}
+}
+
+//WithSubscriptionErrorFunc is an option that receives the errors Run handles without stopping
+func WithSubscriptionErrorFunc(fn ErrorFunc) SubscriptionEngineOption {
+	return func(engine *SubscriptionEngine) {
+		engine.onError = fn
+	}
+}
+
+//Subscribe starts a subscription to a plan paid in coin, billing its first cycle immediately,
+//if that bill fails the subscription is kept and the next Tick bills it again
+func (e *SubscriptionEngine) Subscribe(id, planID, buyerEmail, coin string) (*Subscription, error) {
+	return e.SubscribeContext(context.Background(), id, planID, buyerEmail, coin)
+}
+
+//SubscribeContext starts a subscription to a plan paid in coin, billing its first cycle immediately with the context
+func (e *SubscriptionEngine) SubscribeContext(ctx context.Context, id, planID, buyerEmail, coin string) (*Subscription, error) {
+	if _, ok := e.plans[planID]; !ok {
+		return nil, fmt.Errorf("coinpayments: unknown plan %v", planID)
+	}
+
+	e.mu.Lock()
+	if _, err := e.store.LoadSubscription(id); err == nil {
+		e.mu.Unlock()
+		return nil, fmt.Errorf("coinpayments: subscription %v already exists", id)
+	} else if err != ErrSubscriptionNotFound {
+		e.mu.Unlock()
+		return nil, err
+	}
+
+	now := time.Now()
+	subscription := &Subscription{
+		ID:          id,
+		PlanID:      planID,
+		BuyerEmail:  buyerEmail,
+		Coin:        coin,
+		State:       SubscriptionActive,
+		PaidThrough: now,
+		CreatedAt:   now,
+	}
+
+	job, err := e.advance(subscription, now)
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if job != nil {
+		if err := e.bill(ctx, *job); err != nil {
+			return nil, err
+		}
+	}
+	return e.store.LoadSubscription(id)
+}
+
+//Cancel cancels a subscription, it will not be billed again
+func (e *SubscriptionEngine) Cancel(id string) (*Subscription, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	subscription, err := e.store.LoadSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := subscription.State
+	subscription.State = SubscriptionCancelled
+	subscription.CancelledAt = time.Now()
+	if err := e.save(subscription, previous); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+//Get returns the stored subscription
+func (e *SubscriptionEngine) Get(id string) (*Subscription, error) {
+	return e.store.LoadSubscription(id)
+}
+
+//HandleIPN marks the cycle a completed subscription payment belongs to as paid, extending the subscription unless it was cancelled
+func (e *SubscriptionEngine) HandleIPN(ipn *IPN) error {
+	payment, ok := ipn.payment()
+	if !ok || !strings.HasPrefix(payment.Custom, subscriptionCustomPrefix) {
+		return nil
+	}
+
+	status, err := strconv.Atoi(payment.Status)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid payment status %q - %v", payment.Status, err)
+	}
+	if !paymentComplete(status) {
+		return nil
+	}
+
+	//subscription ids may contain ':', the cycle number follows the last one
+	ref := strings.TrimPrefix(payment.Custom, subscriptionCustomPrefix)
+	sep := strings.LastIndex(ref, ":")
+	if sep < 0 {
+		return fmt.Errorf("coinpayments: malformed subscription reference %q", payment.Custom)
+	}
+	number, err := strconv.Atoi(ref[sep+1:])
+	if err != nil {
+		return fmt.Errorf("coinpayments: malformed subscription reference %q", payment.Custom)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	subscription, err := e.store.LoadSubscription(ref[:sep])
+	if err == ErrSubscriptionNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for i := range subscription.Cycles {
+		cycle := &subscription.Cycles[i]
+		if cycle.Number != number || cycle.Paid() {
+			continue
+		}
+		cycle.PaidAt = time.Now()
+
+		if subscription.State == SubscriptionCancelled {
+			if err := e.save(subscription, subscription.State); err != nil {
+				return err
+			}
+			for _, fn := range e.onCancelled {
+				fn(*subscription.copy(), *cycle)
+			}
+			return nil
+		}
+
+		plan := e.plans[subscription.PlanID]
+		subscription.PaidThrough = cycle.PeriodStart.Add(plan.Interval)
+
+		previous := subscription.State
+		if subscription.State == SubscriptionPastDue {
+			subscription.State = SubscriptionActive
+		}
+		return e.save(subscription, previous)
+	}
+	return nil
+}
+
+//Tick bills due cycles, sends dunning bills and moves unpaid subscriptions to past due or cancelled,
+//a failing subscription does not stop the others and its error is returned in WorkerErrors
+func (e *SubscriptionEngine) Tick() error {
+	return e.TickContext(context.Background())
+}
+
+//TickContext bills due cycles with the context, sends dunning bills and moves unpaid subscriptions to past due or cancelled
+func (e *SubscriptionEngine) TickContext(ctx context.Context) error {
+	e.mu.Lock()
+	subscriptions, err := e.store.ListSubscriptions()
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+
+	now := time.Now()
+	var errs WorkerErrors
+	var jobs []billJob
+	for _, subscription := range subscriptions {
+		if subscription.State == SubscriptionCancelled || e.billing[subscription.ID] {
+			continue
+		}
+		job, err := e.advance(subscription, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("coinpayments: error advancing subscription %v - %v", subscription.ID, err))
+		}
+		if job != nil {
+			jobs = append(jobs, *job)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, job := range jobs {
+		if err := e.bill(ctx, job); err != nil {
+			errs = append(errs, fmt.Errorf("coinpayments: error billing subscription %v - %v", job.subscription.ID, err))
+		}
+	}
+	return errs.err()
+}
+
+//Run ticks on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (e *SubscriptionEngine) Run(ctx context.Context, interval time.Duration) error {
+	return e.client.runEvery(ctx, interval, e.TickContext, e.onError)
+}
+
+//billJob is a cycle to bill once e.mu is released
+type billJob struct {
+	subscription *Subscription
+	plan         Plan
+	number       int
+	dunning      bool
+}
+
+//advance moves a subscription forward to now and saves it, returning the cycle to bill if one is due, the caller must hold e.mu
+func (e *SubscriptionEngine) advance(subscription *Subscription, now time.Time) (*billJob, error) {
+	plan, ok := e.plans[subscription.PlanID]
+	if !ok {
+		return nil, fmt.Errorf("coinpayments: subscription %v has unknown plan %v", subscription.ID, subscription.PlanID)
+	}
+	previous := subscription.State
+
+	var current *SubscriptionCycle
+	if n := len(subscription.Cycles); n > 0 && !subscription.Cycles[n-1].Paid() {
+		current = &subscription.Cycles[n-1]
+	}
+
+	var job *billJob
+	switch {
+	case current == nil && !now.Before(subscription.PaidThrough.Add(-plan.Lead)):
+		subscription.Cycles = append(subscription.Cycles, SubscriptionCycle{
+			Number:      len(subscription.Cycles) + 1,
+			PeriodStart: subscription.PaidThrough,
+		})
+		job = &billJob{plan: plan, number: len(subscription.Cycles)}
+	case current != nil && current.BilledAt.IsZero():
+		//the first bill of the cycle failed, try it again
+		job = &billJob{plan: plan, number: current.Number}
+	case current != nil && now.After(current.DueAt.Add(plan.GracePeriod)):
+		subscription.State = SubscriptionCancelled
+		subscription.CancelledAt = now
+	case current != nil && now.After(current.DueAt):
+		subscription.State = SubscriptionPastDue
+		if plan.DunningInterval > 0 && now.Sub(current.BilledAt) >= plan.DunningInterval {
+			job = &billJob{plan: plan, number: current.Number, dunning: true}
+		}
+	}
+
+	if err := e.save(subscription, previous); err != nil {
+		return nil, err
+	}
+	if job != nil {
+		job.subscription = subscription.copy()
+		e.billing[subscription.ID] = true
+	}
+	return job, nil
+}
+
+//bill creates a fresh transaction for a cycle without holding e.mu, then records it on the stored subscription
+func (e *SubscriptionEngine) bill(ctx context.Context, job billJob) error {
+	subscription, plan := job.subscription, job.plan
+	custom := fmt.Sprintf("%v%v:%v", subscriptionCustomPrefix, subscription.ID, job.number)
+	resp, err := e.client.CreateTransactionContext(ctx, plan.Price, plan.Currency, subscription.Coin, subscription.BuyerEmail, WithOptionalValue("custom", custom))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.billing, subscription.ID)
+	if err != nil {
+		return err
+	}
+
+	//the subscription may have been paid or cancelled while the transaction was created
+	subscription, err = e.store.LoadSubscription(subscription.ID)
+	if err != nil {
+		return err
+	}
+
+	var cycle *SubscriptionCycle
+	for i := range subscription.Cycles {
+		if subscription.Cycles[i].Number == job.number {
+			cycle = &subscription.Cycles[i]
+		}
+	}
+	if cycle == nil {
+		return fmt.Errorf("coinpayments: subscription %v has no cycle %v", subscription.ID, job.number)
+	}
+
+	cycle.TxnIDs = append(cycle.TxnIDs, resp.TxnId)
+	cycle.CheckoutURL = resp.CheckoutURL
+	cycle.BilledAt = time.Now()
+	if !job.dunning {
+		cycle.DueAt = cycle.PeriodStart
+		if expires := cycle.BilledAt.Add(time.Duration(resp.Timeout) * time.Second); expires.After(cycle.DueAt) {
+			cycle.DueAt = expires
+		}
+	}
+
+	if err := e.save(subscription, subscription.State); err != nil {
+		return err
+	}
+
+	if subscription.State != SubscriptionCancelled && !cycle.Paid() {
+		for _, fn := range e.onBilling {
+			fn(*subscription.copy(), *cycle, job.dunning)
+		}
+	}
+	return nil
+}
+
+//save stores the subscription and reports state changes, the caller must hold e.mu
+func (e *SubscriptionEngine) save(subscription *Subscription, previous SubscriptionState) error {
+	if err := e.store.SaveSubscription(subscription); err != nil {
+		return err
+	}
+
+	if subscription.State != previous {
+		for _, fn := range e.onState {
+			fn(*subscription.copy())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package coinpayments
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSubscriptionEngine(t *testing.T, api *fakeAPI, plan Plan, options ...SubscriptionEngineOption) (*SubscriptionEngine, *MemorySubscriptionStore) {
+	store := NewMemorySubscriptionStore()
+	engine, err := NewSubscriptionEngine(api.client(), store, append([]SubscriptionEngineOption{WithPlan(plan)}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, store
+}
+
+func testSubscriptionPlan() Plan {
+	return Plan{ID: "monthly", Price: "10", Currency: "USD", Interval: 30 * 24 * time.Hour, GracePeriod: 72 * time.Hour, DunningInterval: 24 * time.Hour}
+}
+
+func TestNewSubscriptionEngineRejectsInvalidPlans(t *testing.T) {
+	tests := []Plan{
+		{ID: "zero", Price: "10", Currency: "USD"},
+		{ID: "negative", Price: "10", Currency: "USD", Interval: -time.Hour},
+		{ID: "no price", Currency: "USD", Interval: time.Hour},
+		{ID: "no currency", Price: "10", Interval: time.Hour},
+		{ID: "bad price", Price: "ten", Currency: "USD", Interval: time.Hour},
+	}
+
+	for _, plan := range tests {
+		if _, err := NewSubscriptionEngine(nil, NewMemorySubscriptionStore(), WithPlan(plan)); err == nil {
+			t.Errorf("NewSubscriptionEngine accepted plan %q", plan.ID)
+		}
+	}
+}
+
+func TestSubscriptionBillingAndPayment(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_transaction", `{"txn_id":"T1","timeout":3600,"checkout_url":"https://checkout"}`)
+
+	var billed []SubscriptionCycle
+	engine, _ := newTestSubscriptionEngine(t, api, testSubscriptionPlan(), WithSubscriptionBillingFunc(func(s Subscription, cycle SubscriptionCycle, dunning bool) {
+		billed = append(billed, cycle)
+	}))
+
+	subscription, err := engine.Subscribe("user:1", "monthly", "buyer@example.com", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subscription.Cycles) != 1 || subscription.Cycles[0].TxnIDs[0] != "T1" || len(billed) != 1 {
+		t.Fatalf("Subscribe returned cycles %+v and billed %v, want one billed cycle", subscription.Cycles, len(billed))
+	}
+	if custom := api.calls[0].Get("custom"); custom != "sub:user:1:1" {
+		t.Errorf("billed with custom %q, want sub:user:1:1", custom)
+	}
+
+	start := subscription.Cycles[0].PeriodStart
+	err = engine.HandleIPN(newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T1", "custom", "sub:user:1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription, _ = engine.Get("user:1")
+	if !subscription.Cycles[0].Paid() || !subscription.PaidThrough.Equal(start.Add(30*24*time.Hour)) {
+		t.Errorf("after payment cycle is %+v and paid through %v, want paid through %v", subscription.Cycles[0], subscription.PaidThrough, start.Add(30*24*time.Hour))
+	}
+
+	if err := engine.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("create_transaction"); n != 1 {
+		t.Errorf("Tick before the next period billed %v times in total, want 1", n)
+	}
+}
+
+func TestSubscriptionDunningAndCancel(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_transaction", `{"txn_id":"T1","timeout":3600}`)
+
+	var states []SubscriptionState
+	engine, store := newTestSubscriptionEngine(t, api, testSubscriptionPlan(), WithSubscriptionStateFunc(func(s Subscription) {
+		states = append(states, s.State)
+	}))
+	if _, err := engine.Subscribe("s1", "monthly", "buyer@example.com", "BTC"); err != nil {
+		t.Fatal(err)
+	}
+
+	//move the unpaid cycle's due date and bill into the past
+	age := func(d time.Duration) {
+		subscription, _ := store.LoadSubscription("s1")
+		cycle := &subscription.Cycles[0]
+		cycle.DueAt = cycle.DueAt.Add(-d)
+		cycle.BilledAt = cycle.BilledAt.Add(-d)
+		store.SaveSubscription(subscription)
+	}
+
+	age(2 * time.Hour)
+	api.set("create_transaction", `{"txn_id":"T2","timeout":3600}`)
+	if err := engine.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ := engine.Get("s1")
+	if subscription.State != SubscriptionPastDue || len(subscription.Cycles[0].TxnIDs) != 1 {
+		t.Fatalf("after the due date state is %v with txns %v, want past due without a dunning bill", subscription.State, subscription.Cycles[0].TxnIDs)
+	}
+
+	age(24 * time.Hour)
+	if err := engine.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ = engine.Get("s1")
+	if got := subscription.Cycles[0].TxnIDs; len(got) != 2 || got[1] != "T2" {
+		t.Fatalf("after the dunning interval txns are %v, want a second bill", got)
+	}
+
+	age(72 * time.Hour)
+	if err := engine.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ = engine.Get("s1")
+	if subscription.State != SubscriptionCancelled {
+		t.Fatalf("after the grace period state is %v, want cancelled", subscription.State)
+	}
+	if len(states) != 2 || states[0] != SubscriptionPastDue || states[1] != SubscriptionCancelled {
+		t.Errorf("state changes were %v, want past due then cancelled", states)
+	}
+
+	var late []SubscriptionCycle
+	engine.onCancelled = append(engine.onCancelled, func(s Subscription, cycle SubscriptionCycle) {
+		late = append(late, cycle)
+	})
+	if err := engine.HandleIPN(newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T2", "custom", "sub:s1:1")); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ = engine.Get("s1")
+	if subscription.State != SubscriptionCancelled || len(late) != 1 {
+		t.Errorf("a payment on a cancelled subscription left state %v and reported %v payments, want cancelled and 1", subscription.State, len(late))
+	}
+}
+
+func TestSubscriptionRetriesFailedBill(t *testing.T) {
+	api := newFakeAPI()
+	api.fail("create_transaction", "busy")
+	engine, _ := newTestSubscriptionEngine(t, api, testSubscriptionPlan())
+
+	if _, err := engine.Subscribe("s1", "monthly", "buyer@example.com", "BTC"); err == nil {
+		t.Fatal("Subscribe returned nil error for a failed bill")
+	}
+
+	api.set("create_transaction", `{"txn_id":"T1","timeout":3600}`)
+	if err := engine.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ := engine.Get("s1")
+	if len(subscription.Cycles) != 1 || len(subscription.Cycles[0].TxnIDs) != 1 || subscription.State != SubscriptionActive {
+		t.Errorf("after retrying the cycles are %+v in state %v, want one billed active cycle", subscription.Cycles, subscription.State)
+	}
+}
+
+func TestSubscriptionTickReleasesLockDuringBilling(t *testing.T) {
+	api := newFakeAPI()
+	api.set("create_transaction", `{"txn_id":"T1","timeout":3600}`)
+	engine, _ := newTestSubscriptionEngine(t, api, Plan{ID: "monthly", Price: "10", Currency: "USD", Interval: time.Nanosecond})
+	if _, err := engine.Subscribe("s1", "monthly", "buyer@example.com", "BTC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.HandleIPN(newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "T1", "custom", "sub:s1:1")); err != nil {
+		t.Fatal(err)
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	api.hook = func(cmd string) {
+		if strings.HasPrefix(cmd, "create_transaction") {
+			close(entered)
+			<-release
+		}
+	}
+
+	done := make(chan error)
+	go func() { done <- engine.Tick() }()
+	<-entered
+
+	handled := make(chan error)
+	go func() {
+		handled <- engine.HandleIPN(newTestIPN(t, "ipn_type", "api", "status", "100", "txn_id", "X", "custom", "sub:other:1"))
+	}()
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("HandleIPN blocked while Tick was billing")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	subscription, _ := engine.Get("s1")
+	if len(subscription.Cycles) != 2 || len(subscription.Cycles[1].TxnIDs) != 1 {
+		t.Errorf("after Tick the cycles are %+v, want a second billed cycle", subscription.Cycles)
+	}
+}
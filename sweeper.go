@@ -0,0 +1,291 @@
+package coinpayments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SweepRule withdraws a coin to Address once its balance exceeds Level, leaving Reserve behind
+type SweepRule struct {
+	Currency string
+	Level    string
+	Reserve  string
+	//Minimum is the smallest amount worth sweeping, smaller sweeps are skipped to avoid dust
+	Minimum string
+	Address string
+	DestTag string
+}
+
+//SweepRecord is the audit entry of a single sweep
+type SweepRecord struct {
+	ID           string    `json:"id"`
+	Currency     string    `json:"currency"`
+	Balance      string    `json:"balance"`
+	Amount       string    `json:"amount"`
+	Address      string    `json:"address"`
+	DestTag      string    `json:"dest_tag,omitempty"`
+	Trigger      string    `json:"trigger"`
+	DryRun       bool      `json:"dry_run"`
+	WithdrawalID string    `json:"withdrawal_id,omitempty"`
+	Status       int       `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+//SweepLog records every sweep a Sweeper performs or would perform
+type SweepLog interface {
+	Record(record SweepRecord) error
+}
+
+//JSONSweepLog writes sweep records to a writer as one JSON object per line
+type JSONSweepLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+//NewJSONSweepLog returns a JSONSweepLog writing to w
+func NewJSONSweepLog(w io.Writer) *JSONSweepLog {
+	return &JSONSweepLog{enc: json.NewEncoder(w)}
+}
+
+//Record writes the record as a JSON line
+func (l *JSONSweepLog) Record(record SweepRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.enc.Encode(record); err != nil {
+		return fmt.Errorf("coinpayments: error writing sweep record - %v", err)
+	}
+	return nil
+}
+
+//SweeperOption is an option used to modify a Sweeper
+type SweeperOption func(sweeper *Sweeper)
+
+//Sweeper moves coins above a configured level out of the coinpayments wallet
+type Sweeper struct {
+	client      *Client
+	rules       map[string]SweepRule
+	dryRun      bool
+	autoConfirm bool
+	log         SweepLog
+	onError     ErrorFunc
+
+	mu sync.Mutex
+}
+
+//NewSweeper returns a new Sweeper with the applied options
+func NewSweeper(client *Client, options ...SweeperOption) *Sweeper {
+	sweeper := &Sweeper{
+		client: client,
+		rules:  make(map[string]SweepRule),
+	}
+
+	for _, o := range options {
+		o(sweeper)
+	}
+	return sweeper
+}
+
+//WithSweepRule is an option that adds a sweep rule, replacing any earlier rule for the same currency
+func WithSweepRule(rule SweepRule) SweeperOption {
+	return func(sweeper *Sweeper) {
+		sweeper.rules[strings.ToUpper(rule.Currency)] = rule
+	}
+}
+
+//WithSweepDryRun is an option that makes the Sweeper record sweeps without withdrawing
+func WithSweepDryRun() SweeperOption {
+	return func(sweeper *Sweeper) {
+		sweeper.dryRun = true
+	}
+}
+
+//WithSweepAutoConfirm is an option that makes sweep withdrawals skip the coinpayments email confirmation
+func WithSweepAutoConfirm() SweeperOption {
+	return func(sweeper *Sweeper) {
+		sweeper.autoConfirm = true
+	}
+}
+
+//WithSweepLog is an option that makes the Sweeper record every sweep to the log
+func WithSweepLog(log SweepLog) SweeperOption {
+	return func(sweeper *Sweeper) {
+		sweeper.log = log
+	}
+}
+
+//WithSweepErrorFunc is an option that receives the errors Run handles without stopping
+func WithSweepErrorFunc(fn ErrorFunc) SweeperOption {
+	return func(sweeper *Sweeper) {
+		sweeper.onError = fn
+	}
+}
+
+//Sweep checks the balance of every configured coin and sweeps those above their level,
+//a failing coin does not stop the others and its error is returned in WorkerErrors
+func (s *Sweeper) Sweep() ([]SweepRecord, error) {
+	return s.SweepContext(context.Background())
+}
+
+//SweepContext checks the balance of every configured coin with the context and sweeps those above their level
+func (s *Sweeper) SweepContext(ctx context.Context) ([]SweepRecord, error) {
+	return s.sweep(ctx, "poll", nil)
+}
+
+//HandleIPN sweeps the deposited coin when a "deposit" IPN completes, ignoring other IPN types
+func (s *Sweeper) HandleIPN(ipn *IPN) error {
+	if ipn.IPNType != "deposit" {
+		return nil
+	}
+
+	status, err := strconv.Atoi(ipn.depositInformation.Status)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid deposit status %q - %v", ipn.depositInformation.Status, err)
+	}
+
+	coin := strings.ToUpper(ipn.depositInformation.Currency)
+	if _, ok := s.rules[coin]; !ok || status < 100 {
+		return nil
+	}
+
+	_, err = s.sweep(ipn.Context(), "ipn", map[string]bool{coin: true})
+	return err
+}
+
+//Run sweeps on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) error {
+	return s.client.runEvery(ctx, interval, func(ctx context.Context) error {
+		_, err := s.SweepContext(ctx)
+		return err
+	}, s.onError)
+}
+
+//sweep sweeps the given coins, or every configured coin if only is nil
+func (s *Sweeper) sweep(ctx context.Context, trigger string, only map[string]bool) ([]SweepRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balances, err := s.client.BalancesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []SweepRecord
+	var errs WorkerErrors
+	for coin, b := range *balances {
+		coin = strings.ToUpper(coin)
+		rule, ok := s.rules[coin]
+		if !ok || (only != nil && !only[coin]) {
+			continue
+		}
+
+		amount, err := sweepAmount(rule, b.Balancef)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if amount == nil {
+			continue
+		}
+
+		record, err := s.withdraw(ctx, rule, coin, b.Balancef, formatAmount(amount), trigger)
+		records = append(records, record)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("coinpayments: error sweeping %v - %v", coin, err))
+		}
+	}
+	return records, errs.err()
+}
+
+//sweepAmount returns how much of balance a rule sweeps, or nil if the rule does not trigger
+func sweepAmount(rule SweepRule, balance string) (*big.Rat, error) {
+	have, err := parseAmount(balance)
+	if err != nil {
+		return nil, fmt.Errorf("coinpayments: error parsing %v balance - %v", rule.Currency, err)
+	}
+
+	level, err := parseAmount(rule.Level)
+	if err != nil {
+		return nil, fmt.Errorf("coinpayments: invalid sweep level for %v - %v", rule.Currency, err)
+	}
+	if have.Cmp(level) <= 0 {
+		return nil, nil
+	}
+
+	amount := new(big.Rat).Set(have)
+	if rule.Reserve != "" {
+		reserve, err := parseAmount(rule.Reserve)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: invalid sweep reserve for %v - %v", rule.Currency, err)
+		}
+		amount.Sub(amount, reserve)
+	}
+
+	minimum := new(big.Rat)
+	if rule.Minimum != "" {
+		if minimum, err = parseAmount(rule.Minimum); err != nil {
+			return nil, fmt.Errorf("coinpayments: invalid sweep minimum for %v - %v", rule.Currency, err)
+		}
+	}
+	if amount.Sign() <= 0 || amount.Cmp(minimum) < 0 {
+		return nil, nil
+	}
+	return amount, nil
+}
+
+func (s *Sweeper) withdraw(ctx context.Context, rule SweepRule, coin, balance, amount, trigger string) (SweepRecord, error) {
+	id, err := newID()
+	if err != nil {
+		return SweepRecord{}, err
+	}
+
+	record := SweepRecord{
+		ID:       id,
+		Currency: coin,
+		Balance:  balance,
+		Amount:   amount,
+		Address:  rule.Address,
+		DestTag:  rule.DestTag,
+		Trigger:  trigger,
+		DryRun:   s.dryRun,
+		Time:     time.Now(),
+	}
+
+	var withdrawErr error
+	if !s.dryRun {
+		optionals := []OptionalValue{
+			WithOptionalValue("address", rule.Address),
+			WithOptionalValue("note", "sweep "+id),
+		}
+		if rule.DestTag != "" {
+			optionals = append(optionals, WithOptionalValue("dest_tag", rule.DestTag))
+		}
+		if s.autoConfirm {
+			optionals = append(optionals, WithOptionalValue("auto_confirm", "1"))
+		}
+
+		resp, err := s.client.CreateWithdrawalContext(ctx, amount, coin, optionals...)
+		if err != nil {
+			withdrawErr = err
+			record.Error = err.Error()
+		} else {
+			record.WithdrawalID = resp.ID
+			record.Status = resp.Status
+		}
+	}
+
+	if s.log != nil {
+		if err := s.log.Record(record); err != nil && withdrawErr == nil {
+			return record, err
+		}
+	}
+	return record, withdrawErr
+}
@@ -0,0 +1,78 @@
+package coinpayments
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSweepAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    SweepRule
+		balance string
+		want    string
+		wantErr bool
+	}{
+		{name: "below level", rule: SweepRule{Level: "1"}, balance: "0.5"},
+		{name: "at level", rule: SweepRule{Level: "1"}, balance: "1"},
+		{name: "above level", rule: SweepRule{Level: "1"}, balance: "1.5", want: "1.5"},
+		{name: "reserve kept", rule: SweepRule{Level: "1", Reserve: "0.25"}, balance: "1.5", want: "1.25"},
+		{name: "reserve above balance", rule: SweepRule{Level: "1", Reserve: "2"}, balance: "1.5"},
+		{name: "below minimum", rule: SweepRule{Level: "1", Reserve: "1.4", Minimum: "0.2"}, balance: "1.5"},
+		{name: "at minimum", rule: SweepRule{Level: "1", Reserve: "1.3", Minimum: "0.2"}, balance: "1.5", want: "0.2"},
+		{name: "invalid balance", rule: SweepRule{Level: "1"}, balance: "lots", wantErr: true},
+		{name: "invalid level", rule: SweepRule{Level: ""}, balance: "1", wantErr: true},
+		{name: "invalid reserve", rule: SweepRule{Level: "1", Reserve: "x"}, balance: "2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		amount, err := sweepAmount(tt.rule, tt.balance)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: sweepAmount returned nil error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: sweepAmount returned error %v", tt.name, err)
+			continue
+		}
+
+		got := ""
+		if amount != nil {
+			got = formatAmount(amount)
+		}
+		if got != tt.want {
+			t.Errorf("%v: sweepAmount = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSweepDryRun(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", `{"BTC":{"balancef":"2"},"LTC":{"balancef":"2"}}`)
+
+	var log bytes.Buffer
+	sweeper := NewSweeper(api.client(),
+		WithSweepRule(SweepRule{Currency: "btc", Level: "1", Reserve: "0.5", Address: "addr"}),
+		WithSweepDryRun(),
+		WithSweepLog(NewJSONSweepLog(&log)),
+	)
+
+	records, err := sweeper.Sweep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Currency != "BTC" || records[0].Amount != "1.5" || !records[0].DryRun {
+		t.Fatalf("Sweep returned %+v, want a dry run sweep of 1.5 BTC", records)
+	}
+	if n := api.count("create_withdrawal"); n != 0 {
+		t.Errorf("a dry run made %v withdrawals, want none", n)
+	}
+
+	var logged SweepRecord
+	if err := json.Unmarshal(log.Bytes(), &logged); err != nil || logged.ID != records[0].ID || logged.Trigger != "poll" {
+		t.Errorf("logged %q, %v, want the sweep record", log.String(), err)
+	}
+}
@@ -0,0 +1,75 @@
+package coinpayments
+
+import (
+	"context"
+	"net/url"
+)
+
+//SpanAttribute is a key and value recorded on a span
+type SpanAttribute struct {
+	Key   string
+	Value string
+}
+
+//Tracer starts spans around api calls and IPN handling
+type Tracer interface {
+	//Start starts a span as a child of any span in ctx, returning a context carrying the new span
+	Start(ctx context.Context, name string, attributes ...SpanAttribute) (context.Context, Span)
+}
+
+//Span is an operation started by a Tracer
+type Span interface {
+	SetAttributes(attributes ...SpanAttribute)
+	//End finishes the span, marking it as failed if err is not nil
+	End(err error)
+}
+
+//NoopTracer is a Tracer whose spans record nothing, it is used when no tracer is configured
+type NoopTracer struct{}
+
+//Start returns ctx unchanged and a span that records nothing
+func (NoopTracer) Start(ctx context.Context, name string, attributes ...SpanAttribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...SpanAttribute) {}
+
+func (noopSpan) End(err error) {}
+
+//WithTracer is an option that makes the Client trace api calls and IPN parsing with tracer
+func WithTracer(tracer Tracer) ClientOption {
+	return func(client *Client) {
+		client.tracer = tracer
+	}
+}
+
+//tracedValues are the request values recorded on api call spans, nothing else is recorded so keys and addresses never leave the process
+var tracedValues = []string{"currency", "currency1", "currency2", "coin", "from", "to"}
+
+func commandAttributes(cmd string, values url.Values) []SpanAttribute {
+	attributes := []SpanAttribute{{"coinpayments.command", cmd}}
+	for _, key := range tracedValues {
+		if v := values.Get(key); v != "" {
+			attributes = append(attributes, SpanAttribute{"coinpayments." + key, v})
+		}
+	}
+	return attributes
+}
+
+func resultAttribute(category ErrorCategory) SpanAttribute {
+	if category == ErrorNone {
+		return SpanAttribute{"coinpayments.result", "ok"}
+	}
+	return SpanAttribute{"coinpayments.result", string(category)}
+}
+
+func ipnAttributes(ipn *IPN, category ErrorCategory) []SpanAttribute {
+	attributes := []SpanAttribute{resultAttribute(category)}
+	if ipn != nil {
+		ipnType, status := ipnLabels(ipn)
+		attributes = append(attributes, SpanAttribute{"coinpayments.ipn_type", ipnType}, SpanAttribute{"coinpayments.status", status})
+	}
+	return attributes
+}
@@ -0,0 +1,45 @@
+package coinpayments
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type spanNameKey struct{}
+
+type recordingTracer struct {
+	started []string
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attributes ...SpanAttribute) (context.Context, Span) {
+	t.started = append(t.started, name)
+	return context.WithValue(ctx, spanNameKey{}, name), noopSpan{}
+}
+
+func TestParseIPNContextCarriesSpan(t *testing.T) {
+	tracer := &recordingTracer{}
+	client := NewClient("", "", WithTracer(tracer))
+
+	ipn, err := client.ParseIPN(httptest.NewRequest("POST", "/ipn", strings.NewReader(testIPNBody)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := ipn.Context().Value(spanNameKey{}).(string); name != "coinpayments.ipn.parse" {
+		t.Errorf("IPN context carries span %q, want coinpayments.ipn.parse", name)
+	}
+}
+
+func TestCommandAttributes(t *testing.T) {
+	values := map[string][]string{"currency": {"BTC"}, "address": {"secret"}, "amount": {"1"}}
+	attributes := commandAttributes("create_withdrawal", values)
+
+	got := map[string]string{}
+	for _, a := range attributes {
+		got[a.Key] = a.Value
+	}
+	if len(got) != 2 || got["coinpayments.command"] != "create_withdrawal" || got["coinpayments.currency"] != "BTC" {
+		t.Errorf("commandAttributes returned %v, want only the command and currency", attributes)
+	}
+}
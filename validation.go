@@ -0,0 +1,263 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+//ValidationError describes a single failed pre-flight check
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("coinpayments: invalid %v - %v", e.Field, e.Reason)
+}
+
+//ValidationErrors is returned when one or more pre-flight checks fail
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	reasons := make([]string, len(v))
+	for i, e := range v {
+		reasons[i] = fmt.Sprintf("%v: %v", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("coinpayments: pre-flight validation failed - %v", strings.Join(reasons, "; "))
+}
+
+func (v *ValidationErrors) add(field, format string, args ...interface{}) {
+	*v = append(*v, &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+//destTagCurrencies are the coins whose withdrawals to a raw address must carry a destination tag
+var destTagCurrencies = map[string]bool{
+	"XRP": true,
+	"XLM": true,
+	"XEM": true,
+	"EOS": true,
+	"BNB": true,
+}
+
+//WithPreflightValidation is an option that makes the Client validate withdrawals and conversions before sending them
+func WithPreflightValidation() ClientOption {
+	return func(client *Client) {
+		client.preflight = true
+	}
+}
+
+//ValidateWithdrawal runs the pre-flight checks for a "create_withdrawal" call without sending it
+func (c *Client) ValidateWithdrawal(amount, currency string, optionals ...OptionalValue) error {
+	return c.ValidateWithdrawalContext(context.Background(), amount, currency, optionals...)
+}
+
+//ValidateWithdrawalContext runs the pre-flight checks for a "create_withdrawal" call with the context without sending it
+func (c *Client) ValidateWithdrawalContext(ctx context.Context, amount, currency string, optionals ...OptionalValue) error {
+	values := &url.Values{}
+	values.Set("amount", amount)
+	values.Set("currency", currency)
+	addOptionals(optionals, values)
+
+	return c.validateWithdrawal(ctx, values)
+}
+
+//ValidateConversion runs the pre-flight checks for a "convert" call without sending it
+func (c *Client) ValidateConversion(amount, from, to string, optionals ...OptionalValue) error {
+	return c.ValidateConversionContext(context.Background(), amount, from, to, optionals...)
+}
+
+//ValidateConversionContext runs the pre-flight checks for a "convert" call with the context without sending it
+func (c *Client) ValidateConversionContext(ctx context.Context, amount, from, to string, optionals ...OptionalValue) error {
+	values := &url.Values{}
+	values.Set("amount", amount)
+	values.Set("from", from)
+	values.Set("to", to)
+	addOptionals(optionals, values)
+
+	return c.validateConversion(ctx, values)
+}
+
+func (c *Client) validateWithdrawal(ctx context.Context, values *url.Values) error {
+	var errs ValidationErrors
+
+	currency := strings.ToUpper(values.Get("currency"))
+	if currency == "" {
+		errs.add("currency", "must be set")
+	}
+
+	var destinations []string
+	for _, key := range []string{"address", "pbntag", "domain"} {
+		if values.Get(key) != "" {
+			destinations = append(destinations, key)
+		}
+	}
+	switch len(destinations) {
+	case 0:
+		errs.add("destination", "one of address, pbntag or domain must be set")
+	case 1:
+	default:
+		errs.add("destination", "only one of address, pbntag or domain may be set, got %v", strings.Join(destinations, ", "))
+	}
+
+	if values.Get("address") != "" && destTagCurrencies[currency] && values.Get("dest_tag") == "" {
+		errs.add("dest_tag", "required for %v withdrawals to an address", currency)
+	}
+
+	amount, err := parseAmount(values.Get("amount"))
+	if err != nil {
+		errs.add("amount", "%v", err)
+	} else if amount.Sign() <= 0 {
+		errs.add("amount", "must be greater than zero")
+	}
+
+	if len(errs) > 0 || currency == "" {
+		return errs.err()
+	}
+
+	cost, err := c.withdrawalCost(ctx, currency, amount, values, &errs)
+	if err != nil {
+		return err
+	}
+	if cost == nil {
+		return errs.err()
+	}
+
+	if err := c.checkBalance(ctx, currency, cost, &errs); err != nil {
+		return err
+	}
+
+	return errs.err()
+}
+
+//withdrawalCost returns how much of the currency balance a withdrawal takes, converting an amount given in currency2
+//and adding the network fee when add_tx_fee is set, it returns nil if the cost is unknown and only api errors are returned
+func (c *Client) withdrawalCost(ctx context.Context, currency string, amount *big.Rat, values *url.Values, errs *ValidationErrors) (*big.Rat, error) {
+	currency2 := strings.ToUpper(values.Get("currency2"))
+	addFee := values.Get("add_tx_fee") == "1"
+	if (currency2 == "" || currency2 == currency) && !addFee {
+		return amount, nil
+	}
+
+	rates, err := c.RatesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, ok := (*rates)[currency]
+	if !ok {
+		errs.add("currency", "no rate for %v", currency)
+		return nil, nil
+	}
+
+	cost := new(big.Rat).Set(amount)
+	if currency2 != "" && currency2 != currency {
+		rate2, ok := (*rates)[currency2]
+		if !ok {
+			errs.add("currency2", "no rate for %v", currency2)
+			return nil, nil
+		}
+
+		from, err := parseAmount(rate2.RateBTC)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error parsing %v rate - %v", currency2, err)
+		}
+		to, err := parseAmount(rate.RateBTC)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error parsing %v rate - %v", currency, err)
+		}
+		if to.Sign() == 0 {
+			errs.add("currency", "zero rate for %v", currency)
+			return nil, nil
+		}
+		cost.Mul(cost, from)
+		cost.Quo(cost, to)
+	}
+
+	if addFee && rate.TxFee != "" {
+		fee, err := parseAmount(rate.TxFee)
+		if err != nil {
+			return nil, fmt.Errorf("coinpayments: error parsing %v tx fee - %v", currency, err)
+		}
+		cost.Add(cost, fee)
+	}
+	return cost, nil
+}
+
+func (c *Client) validateConversion(ctx context.Context, values *url.Values) error {
+	var errs ValidationErrors
+
+	from := strings.ToUpper(values.Get("from"))
+	to := strings.ToUpper(values.Get("to"))
+	if from == "" {
+		errs.add("from", "must be set")
+	}
+	if to == "" {
+		errs.add("to", "must be set")
+	}
+	if from != "" && from == to {
+		errs.add("to", "must differ from the source currency")
+	}
+
+	amount, err := parseAmount(values.Get("amount"))
+	if err != nil {
+		errs.add("amount", "%v", err)
+	} else if amount.Sign() <= 0 {
+		errs.add("amount", "must be greater than zero")
+	}
+
+	if len(errs) > 0 {
+		return errs.err()
+	}
+
+	limits, err := c.ConvertLimitsContext(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	if min, err := parseAmount(limits.Min); err == nil && amount.Cmp(min) < 0 {
+		errs.add("amount", "below the conversion minimum of %v %v", limits.Min, from)
+	}
+	if max, err := parseAmount(limits.Max); err == nil && max.Sign() > 0 && amount.Cmp(max) > 0 {
+		errs.add("amount", "above the conversion maximum of %v %v", limits.Max, from)
+	}
+
+	if err := c.checkBalance(ctx, from, amount, &errs); err != nil {
+		return err
+	}
+
+	return errs.err()
+}
+
+//checkBalance adds a validation error if the wallet cannot cover want, returning only api errors
+func (c *Client) checkBalance(ctx context.Context, currency string, want *big.Rat, errs *ValidationErrors) error {
+	balances, err := c.BalancesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	balance, ok := (*balances)[currency]
+	if !ok {
+		errs.add("currency", "no %v balance in wallet", currency)
+		return nil
+	}
+
+	have, err := parseAmount(balance.Balancef)
+	if err != nil {
+		return fmt.Errorf("coinpayments: error parsing %v balance - %v", currency, err)
+	}
+
+	if want.Cmp(have) > 0 {
+		errs.add("amount", "needs %v %v, more than the balance of %v", formatAmount(want), currency, balance.Balancef)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package coinpayments
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const testRates = `{"BTC":{"rate_btc":"1","tx_fee":"0.0005"},"LTC":{"rate_btc":"0.002","tx_fee":"0.001"},"USD":{"rate_btc":"0.00002","is_fiat":1}}`
+
+func TestValidateWithdrawal(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		currency  string
+		optionals []OptionalValue
+		field     string
+	}{
+		{"within balance", "0.5", "BTC", []OptionalValue{WithOptionalValue("address", "a")}, ""},
+		{"exceeds balance", "1.5", "BTC", []OptionalValue{WithOptionalValue("address", "a")}, "amount"},
+		{"fee pushes over balance", "0.9999", "BTC", []OptionalValue{WithOptionalValue("address", "a"), WithOptionalValue("add_tx_fee", "1")}, "amount"},
+		{"fee within balance", "0.999", "BTC", []OptionalValue{WithOptionalValue("address", "a"), WithOptionalValue("add_tx_fee", "1")}, ""},
+		{"currency2 within balance", "40000", "BTC", []OptionalValue{WithOptionalValue("address", "a"), WithOptionalValue("currency2", "USD")}, ""},
+		{"currency2 exceeds balance", "60000", "BTC", []OptionalValue{WithOptionalValue("address", "a"), WithOptionalValue("currency2", "usd")}, "amount"},
+		{"currency2 without rate", "1", "BTC", []OptionalValue{WithOptionalValue("address", "a"), WithOptionalValue("currency2", "EUR")}, "currency2"},
+		{"no destination", "0.5", "BTC", nil, "destination"},
+		{"two destinations", "0.5", "BTC", []OptionalValue{WithOptionalValue("address", "a"), WithOptionalValue("pbntag", "$b")}, "destination"},
+		{"missing dest tag", "0.5", "XRP", []OptionalValue{WithOptionalValue("address", "a")}, "dest_tag"},
+		{"zero amount", "0", "BTC", []OptionalValue{WithOptionalValue("address", "a")}, "amount"},
+		{"no wallet", "0.5", "LTC", []OptionalValue{WithOptionalValue("address", "a")}, "currency"},
+	}
+
+	api := newFakeAPI()
+	api.set("balances", `{"BTC":{"balancef":"1.00000000"}}`)
+	api.set("rates", testRates)
+	client := api.client()
+
+	for _, tt := range tests {
+		err := client.ValidateWithdrawal(tt.amount, tt.currency, tt.optionals...)
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%v: ValidateWithdrawal returned %v, want nil", tt.name, err)
+			}
+			continue
+		}
+
+		errs, ok := err.(ValidationErrors)
+		if !ok || len(errs) == 0 || errs[0].Field != tt.field {
+			t.Errorf("%v: ValidateWithdrawal returned %v, want a %v error", tt.name, err, tt.field)
+		}
+	}
+}
+
+func TestValidateWithdrawalSkipsRatesWithoutConversion(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", `{"BTC":{"balancef":"1.00000000"}}`)
+
+	if err := api.client().ValidateWithdrawal("0.5", "BTC", WithOptionalValue("address", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("rates"); n != 0 {
+		t.Errorf("rates called %v times, want 0", n)
+	}
+}
+
+func TestValidateConversion(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", `{"LTC":{"balancef":"10.00000000"}}`)
+	api.set("convert_limits", `{"min":"0.1","max":"5"}`)
+	client := api.client()
+
+	tests := []struct {
+		amount string
+		from   string
+		to     string
+		reason string
+	}{
+		{"1", "LTC", "BTC", ""},
+		{"0.01", "LTC", "BTC", "below the conversion minimum"},
+		{"6", "LTC", "BTC", "above the conversion maximum"},
+		{"1", "LTC", "ltc", "must differ"},
+	}
+
+	for _, tt := range tests {
+		err := client.ValidateConversion(tt.amount, tt.from, tt.to)
+		switch {
+		case tt.reason == "" && err != nil:
+			t.Errorf("ValidateConversion(%v %v to %v) returned %v, want nil", tt.amount, tt.from, tt.to, err)
+		case tt.reason != "" && (err == nil || !strings.Contains(err.Error(), tt.reason)):
+			t.Errorf("ValidateConversion(%v %v to %v) returned %v, want %q", tt.amount, tt.from, tt.to, err, tt.reason)
+		}
+	}
+}
+
+func TestPreflightUsesContext(t *testing.T) {
+	api := newFakeAPI()
+	api.set("balances", `{"BTC":{"balancef":"1.00000000"}}`)
+	client := api.client(WithPreflightValidation())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.CreateWithdrawalContext(ctx, "0.5", "BTC", WithOptionalValue("address", "a")); err == nil {
+		t.Fatal("CreateWithdrawalContext with a cancelled context returned nil error")
+	}
+	if len(api.calls) != 0 {
+		t.Errorf("made %v api calls with a cancelled context, want 0", len(api.calls))
+	}
+}
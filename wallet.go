@@ -1,6 +1,10 @@
 package coinpayments
 
-import "net/url"
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
 
 //balancesResponse is the api response of a "balances" call
 type balancesResponse map[string]struct {
@@ -11,6 +15,11 @@ type balancesResponse map[string]struct {
 
 //Balances calls the "balances" command
 func (c *Client) Balances(optionals ...OptionalValue) (*balancesResponse, error) {
+	return c.BalancesContext(context.Background(), optionals...)
+}
+
+//BalancesContext calls the "balances" command with the context
+func (c *Client) BalancesContext(ctx context.Context, optionals ...OptionalValue) (*balancesResponse, error) {
 	values := &url.Values{}
 	addOptionals(optionals, values)
 
@@ -18,7 +27,7 @@ func (c *Client) Balances(optionals ...OptionalValue) (*balancesResponse, error)
 		errResponse
 		Result *balancesResponse `json:"result"`
 	}
-	if err := c.call("balances", values, &resp); err != nil {
+	if err := c.callContext(ctx, "balances", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -34,6 +43,11 @@ type getDepositAddressResponse struct {
 
 //GetDepositAddress calls the "get_deposit_address" command
 func (c *Client) GetDepositAddress(currency string, optionals ...OptionalValue) (*getDepositAddressResponse, error) {
+	return c.GetDepositAddressContext(context.Background(), currency, optionals...)
+}
+
+//GetDepositAddressContext calls the "get_deposit_address" command with the context
+func (c *Client) GetDepositAddressContext(ctx context.Context, currency string, optionals ...OptionalValue) (*getDepositAddressResponse, error) {
 	values := &url.Values{}
 	values.Set("currency", currency)
 	addOptionals(optionals, values)
@@ -42,7 +56,7 @@ func (c *Client) GetDepositAddress(currency string, optionals ...OptionalValue)
 		errResponse
 		Result *getDepositAddressResponse `json:"result"`
 	}
-	if err := c.call("get_deposit_address", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_deposit_address", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -57,6 +71,11 @@ type createTransferResponse struct {
 
 //CreateTransfer calls the "create_transfer" command
 func (c *Client) CreateTransfer(amount, currency string, optionals ...OptionalValue) (*createTransferResponse, error) {
+	return c.CreateTransferContext(context.Background(), amount, currency, optionals...)
+}
+
+//CreateTransferContext calls the "create_transfer" command with the context
+func (c *Client) CreateTransferContext(ctx context.Context, amount, currency string, optionals ...OptionalValue) (*createTransferResponse, error) {
 	values := &url.Values{}
 	values.Set("amount", amount)
 	values.Set("currency", currency)
@@ -67,7 +86,7 @@ func (c *Client) CreateTransfer(amount, currency string, optionals ...OptionalVa
 		Result *createTransferResponse `json:"result"`
 	}
 
-	if err := c.call("create_transfer", values, &resp); err != nil {
+	if err := c.callContext(ctx, "create_transfer", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -83,16 +102,88 @@ type createWithdrawalResponse struct {
 
 //CreateWithdrawal calls the "create_withdrawal" command
 func (c *Client) CreateWithdrawal(amount, currency string, optionals ...OptionalValue) (*createWithdrawalResponse, error) {
+	return c.CreateWithdrawalContext(context.Background(), amount, currency, optionals...)
+}
+
+//CreateWithdrawalContext calls the "create_withdrawal" command with the context
+func (c *Client) CreateWithdrawalContext(ctx context.Context, amount, currency string, optionals ...OptionalValue) (*createWithdrawalResponse, error) {
 	values := &url.Values{}
 	values.Set("amount", amount)
 	values.Set("currency", currency)
 	addOptionals(optionals, values)
 
+	if c.validateAddresses {
+		if err := validateAddressValues(currency, values.Get("address"), values.Get("dest_tag")); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.preflight {
+		if err := c.validateWithdrawal(ctx, values); err != nil {
+			return nil, err
+		}
+	}
+
 	var resp struct {
 		errResponse
 		Result *createWithdrawalResponse `json:"result"`
 	}
-	if err := c.call("create_withdrawal", values, &resp); err != nil {
+	if err := c.callContext(ctx, "create_withdrawal", values, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Result, nil
+}
+
+//MassWithdrawal is a single withdrawal in a "create_mass_withdrawal" call
+type MassWithdrawal struct {
+	Amount   string
+	Currency string
+	Address  string
+	PBNTag   string
+	Domain   string
+	DestTag  string
+}
+
+//createMassWithdrawalResponse is the api response of a "create_mass_withdrawal" call
+type createMassWithdrawalResponse map[string]struct {
+	Error  string `json:"error"`
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+	Amount string `json:"amount"`
+}
+
+//CreateMassWithdrawal calls the "create_mass_withdrawal" command, with withdrawals keyed by the name they are reported under
+func (c *Client) CreateMassWithdrawal(withdrawals map[string]MassWithdrawal, optionals ...OptionalValue) (*createMassWithdrawalResponse, error) {
+	return c.CreateMassWithdrawalContext(context.Background(), withdrawals, optionals...)
+}
+
+//CreateMassWithdrawalContext calls the "create_mass_withdrawal" command with the context
+func (c *Client) CreateMassWithdrawalContext(ctx context.Context, withdrawals map[string]MassWithdrawal, optionals ...OptionalValue) (*createMassWithdrawalResponse, error) {
+	values := &url.Values{}
+	for name, wd := range withdrawals {
+		if c.validateAddresses {
+			if err := validateAddressValues(wd.Currency, wd.Address, wd.DestTag); err != nil {
+				return nil, fmt.Errorf("coinpayments: withdrawal %v - %v", name, err)
+			}
+		}
+
+		prefix := "wd[" + name + "]"
+		values.Set(prefix+"[amount]", wd.Amount)
+		values.Set(prefix+"[currency]", wd.Currency)
+		for key, value := range map[string]string{"address": wd.Address, "pbntag": wd.PBNTag, "domain": wd.Domain, "dest_tag": wd.DestTag} {
+			if value != "" {
+				values.Set(prefix+"["+key+"]", value)
+			}
+		}
+	}
+	addOptionals(optionals, values)
+
+	var resp struct {
+		errResponse
+		Result *createMassWithdrawalResponse `json:"result"`
+	}
+	if err := c.callContext(ctx, "create_mass_withdrawal", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -102,8 +193,13 @@ func (c *Client) CreateWithdrawal(amount, currency string, optionals ...Optional
 //cancelWithdrawalResponse is the api response of a "cancel_withdrawal" call
 type cancelWithdrawalResponse struct{}
 
-//CancelWithdrawal calls the "create_withdrawal" command
+//CancelWithdrawal calls the "cancel_withdrawal" command
 func (c *Client) CancelWithdrawal(id string, optionals ...OptionalValue) (*cancelWithdrawalResponse, error) {
+	return c.CancelWithdrawalContext(context.Background(), id, optionals...)
+}
+
+//CancelWithdrawalContext calls the "cancel_withdrawal" command with the context
+func (c *Client) CancelWithdrawalContext(ctx context.Context, id string, optionals ...OptionalValue) (*cancelWithdrawalResponse, error) {
 	values := &url.Values{}
 	values.Set("id", id)
 	addOptionals(optionals, values)
@@ -112,7 +208,7 @@ func (c *Client) CancelWithdrawal(id string, optionals ...OptionalValue) (*cance
 		errResponse
 		Result *cancelWithdrawalResponse `json:"result"`
 	}
-	if err := c.call("cancel_withdrawal", values, &resp); err != nil {
+	if err := c.callContext(ctx, "cancel_withdrawal", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -126,17 +222,28 @@ type convertResponse struct {
 
 //Convert calls the "convert" command
 func (c *Client) Convert(amount, from, to string, optionals ...OptionalValue) (*convertResponse, error) {
+	return c.ConvertContext(context.Background(), amount, from, to, optionals...)
+}
+
+//ConvertContext calls the "convert" command with the context
+func (c *Client) ConvertContext(ctx context.Context, amount, from, to string, optionals ...OptionalValue) (*convertResponse, error) {
 	values := &url.Values{}
 	values.Set("amount", amount)
 	values.Set("from", from)
 	values.Set("to", to)
 	addOptionals(optionals, values)
 
+	if c.preflight {
+		if err := c.validateConversion(ctx, values); err != nil {
+			return nil, err
+		}
+	}
+
 	var resp struct {
 		errResponse
 		Result *convertResponse `json:"result"`
 	}
-	if err := c.call("convert", values, &resp); err != nil {
+	if err := c.callContext(ctx, "convert", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -151,6 +258,11 @@ type convertLimitsResponse struct {
 
 //ConvertLimits calls the "convert_limits" command
 func (c *Client) ConvertLimits(from, to string, optionals ...OptionalValue) (*convertLimitsResponse, error) {
+	return c.ConvertLimitsContext(context.Background(), from, to, optionals...)
+}
+
+//ConvertLimitsContext calls the "convert_limits" command with the context
+func (c *Client) ConvertLimitsContext(ctx context.Context, from, to string, optionals ...OptionalValue) (*convertLimitsResponse, error) {
 	values := &url.Values{}
 	values.Set("from", from)
 	values.Set("to", to)
@@ -160,7 +272,7 @@ func (c *Client) ConvertLimits(from, to string, optionals ...OptionalValue) (*co
 		errResponse
 		Result *convertLimitsResponse `json:"result"`
 	}
-	if err := c.call("convert_limits", values, &resp); err != nil {
+	if err := c.callContext(ctx, "convert_limits", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -184,6 +296,11 @@ type getWithdrawalHistoryResponse []struct {
 
 //GetWithdrawalHistory calls the "get_withdrawal_history" command
 func (c *Client) GetWithdrawalHistory(optionals ...OptionalValue) (*getWithdrawalHistoryResponse, error) {
+	return c.GetWithdrawalHistoryContext(context.Background(), optionals...)
+}
+
+//GetWithdrawalHistoryContext calls the "get_withdrawal_history" command with the context
+func (c *Client) GetWithdrawalHistoryContext(ctx context.Context, optionals ...OptionalValue) (*getWithdrawalHistoryResponse, error) {
 	values := &url.Values{}
 	addOptionals(optionals, values)
 
@@ -191,7 +308,7 @@ func (c *Client) GetWithdrawalHistory(optionals ...OptionalValue) (*getWithdrawa
 		errResponse
 		Result *getWithdrawalHistoryResponse `json:"result"`
 	}
-	if err := c.call("get_withdrawal_history", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_withdrawal_history", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -213,6 +330,11 @@ type getWithdrawalInfoResponse struct {
 
 //GetWithdrawalInfo calls the "get_withdrawal_info" command
 func (c *Client) GetWithdrawalInfo(id string, optionals ...OptionalValue) (*getWithdrawalInfoResponse, error) {
+	return c.GetWithdrawalInfoContext(context.Background(), id, optionals...)
+}
+
+//GetWithdrawalInfoContext calls the "get_withdrawal_info" command with the context
+func (c *Client) GetWithdrawalInfoContext(ctx context.Context, id string, optionals ...OptionalValue) (*getWithdrawalInfoResponse, error) {
 	values := &url.Values{}
 	values.Set("id", id)
 	addOptionals(optionals, values)
@@ -221,7 +343,7 @@ func (c *Client) GetWithdrawalInfo(id string, optionals ...OptionalValue) (*getW
 		errResponse
 		Result *getWithdrawalInfoResponse `json:"result"`
 	}
-	if err := c.call("get_withdrawal_info", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_withdrawal_info", values, &resp); err != nil {
 		return nil, err
 	}
 
@@ -243,6 +365,11 @@ type getConversionInfoResponse struct {
 
 //GetConversionInfo calls the "get_conversion_info" command
 func (c *Client) GetConversionInfo(id string, optionals ...OptionalValue) (*getConversionInfoResponse, error) {
+	return c.GetConversionInfoContext(context.Background(), id, optionals...)
+}
+
+//GetConversionInfoContext calls the "get_conversion_info" command with the context
+func (c *Client) GetConversionInfoContext(ctx context.Context, id string, optionals ...OptionalValue) (*getConversionInfoResponse, error) {
 	values := &url.Values{}
 	values.Set("id", id)
 	addOptionals(optionals, values)
@@ -252,7 +379,7 @@ func (c *Client) GetConversionInfo(id string, optionals ...OptionalValue) (*getC
 		Result *getConversionInfoResponse `json:"result"`
 	}
 
-	if err := c.call("get_conversion_info", values, &resp); err != nil {
+	if err := c.callContext(ctx, "get_conversion_info", values, &resp); err != nil {
 		return nil, err
 	}
 
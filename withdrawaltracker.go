@@ -0,0 +1,281 @@
+package coinpayments
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//WithdrawalPhase is the lifecycle stage of a tracked withdrawal
+type WithdrawalPhase string
+
+//Withdrawal phases, in the order a withdrawal moves through them
+const (
+	WithdrawalCreated      WithdrawalPhase = "created"
+	WithdrawalEmailConfirm WithdrawalPhase = "email_confirm"
+	WithdrawalPending      WithdrawalPhase = "pending"
+	WithdrawalSent         WithdrawalPhase = "sent"
+	WithdrawalComplete     WithdrawalPhase = "complete"
+	WithdrawalCancelled    WithdrawalPhase = "cancelled"
+)
+
+var withdrawalPhaseRank = map[WithdrawalPhase]int{
+	WithdrawalCreated:      0,
+	WithdrawalEmailConfirm: 1,
+	WithdrawalPending:      2,
+	WithdrawalSent:         3,
+	WithdrawalComplete:     4,
+	WithdrawalCancelled:    4,
+}
+
+//Terminal reports whether a withdrawal in this phase can no longer change
+func (p WithdrawalPhase) Terminal() bool {
+	return p == WithdrawalComplete || p == WithdrawalCancelled
+}
+
+//withdrawalPhase maps a coinpayments withdrawal status to a phase
+func withdrawalPhase(status int, sendTXID string) WithdrawalPhase {
+	switch {
+	case status < 0:
+		return WithdrawalCancelled
+	case status == 0:
+		return WithdrawalEmailConfirm
+	case status == 1 && sendTXID != "":
+		return WithdrawalSent
+	case status == 1:
+		return WithdrawalPending
+	default:
+		return WithdrawalComplete
+	}
+}
+
+//TrackedWithdrawal is the merged state of a withdrawal from IPNs and polling
+type TrackedWithdrawal struct {
+	ID         string
+	Phase      WithdrawalPhase
+	Status     int
+	StatusText string
+	Currency   string
+	Amount     string
+	Address    string
+	SendTXID   string
+	Source     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+//WithdrawalChangeFunc is called when a tracked withdrawal changes phase or learns its send txid
+type WithdrawalChangeFunc func(previous, current TrackedWithdrawal)
+
+//WithdrawalTrackerOption is an option used to modify a WithdrawalTracker
+type WithdrawalTrackerOption func(tracker *WithdrawalTracker)
+
+//WithdrawalTracker correlates withdrawal IPNs and "get_withdrawal_info" polling by withdrawal id
+type WithdrawalTracker struct {
+	client    *Client
+	callbacks []WithdrawalChangeFunc
+	onError   ErrorFunc
+
+	mu          sync.Mutex
+	withdrawals map[string]*TrackedWithdrawal
+}
+
+//NewWithdrawalTracker returns a new WithdrawalTracker with the applied options
+func NewWithdrawalTracker(client *Client, options ...WithdrawalTrackerOption) *WithdrawalTracker {
+	tracker := &WithdrawalTracker{
+		client:      client,
+		withdrawals: make(map[string]*TrackedWithdrawal),
+	}
+
+	for _, o := range options {
+		o(tracker)
+	}
+	return tracker
+}
+
+//WithWithdrawalChangeFunc is an option that registers a callback for withdrawal changes
+func WithWithdrawalChangeFunc(fn WithdrawalChangeFunc) WithdrawalTrackerOption {
+	return func(tracker *WithdrawalTracker) {
+		tracker.callbacks = append(tracker.callbacks, fn)
+	}
+}
+
+//WithWithdrawalTrackerErrorFunc is an option that receives the errors Run handles without stopping
+func WithWithdrawalTrackerErrorFunc(fn ErrorFunc) WithdrawalTrackerOption {
+	return func(tracker *WithdrawalTracker) {
+		tracker.onError = fn
+	}
+}
+
+//Track starts tracking a withdrawal id returned by "create_withdrawal"
+func (t *WithdrawalTracker) Track(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.withdrawals[id]; ok {
+		return
+	}
+
+	now := time.Now()
+	t.withdrawals[id] = &TrackedWithdrawal{
+		ID:        id,
+		Phase:     WithdrawalCreated,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+//HandleIPN updates tracked state from a "withdrawal" IPN, ignoring other IPN types
+func (t *WithdrawalTracker) HandleIPN(ipn *IPN) error {
+	if ipn.IPNType != "withdrawal" {
+		return nil
+	}
+
+	info := ipn.withdrawalInformation
+	status, err := strconv.Atoi(info.Status)
+	if err != nil {
+		return fmt.Errorf("coinpayments: invalid withdrawal status %q - %v", info.Status, err)
+	}
+
+	t.update(TrackedWithdrawal{
+		ID:         info.ID,
+		Status:     status,
+		StatusText: info.StatusText,
+		Currency:   info.Currency,
+		Amount:     info.Amount,
+		Address:    info.Address,
+		SendTXID:   info.TransactionID,
+		Source:     "ipn",
+	})
+	return nil
+}
+
+//Poll calls "get_withdrawal_info" for every tracked withdrawal that has not finished,
+//a failing withdrawal does not stop the others and its error is returned in WorkerErrors
+func (t *WithdrawalTracker) Poll() error {
+	return t.PollContext(context.Background())
+}
+
+//PollContext calls "get_withdrawal_info" for every tracked withdrawal that has not finished with the context
+func (t *WithdrawalTracker) PollContext(ctx context.Context) error {
+	var ids []string
+	t.mu.Lock()
+	for id, w := range t.withdrawals {
+		if !w.Phase.Terminal() {
+			ids = append(ids, id)
+		}
+	}
+	t.mu.Unlock()
+
+	var errs WorkerErrors
+	for _, id := range ids {
+		info, err := t.client.GetWithdrawalInfoContext(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("coinpayments: error polling withdrawal %v - %v", id, err))
+			continue
+		}
+
+		t.update(TrackedWithdrawal{
+			ID:         id,
+			Status:     info.Status,
+			StatusText: info.StatusText,
+			Currency:   info.Coin,
+			Amount:     info.Amountf,
+			Address:    info.SendAddress,
+			SendTXID:   info.SendTXID,
+			Source:     "poll",
+			CreatedAt:  time.Unix(int64(info.TimeCreated), 0),
+		})
+	}
+	return errs.err()
+}
+
+//Run polls on the given interval until the context is done, reporting errors to the error func or the client's logger
+func (t *WithdrawalTracker) Run(ctx context.Context, interval time.Duration) error {
+	return t.client.runEvery(ctx, interval, t.PollContext, t.onError)
+}
+
+//Get returns the tracked state of a withdrawal
+func (t *WithdrawalTracker) Get(id string) (TrackedWithdrawal, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.withdrawals[id]
+	if !ok {
+		return TrackedWithdrawal{}, false
+	}
+	return *w, true
+}
+
+//List returns the tracked withdrawals in any of the given phases, or all of them if none are given
+func (t *WithdrawalTracker) List(phases ...WithdrawalPhase) []TrackedWithdrawal {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var list []TrackedWithdrawal
+	for _, w := range t.withdrawals {
+		if len(phases) == 0 {
+			list = append(list, *w)
+			continue
+		}
+		for _, p := range phases {
+			if w.Phase == p {
+				list = append(list, *w)
+				break
+			}
+		}
+	}
+	return list
+}
+
+//update merges an observation into the tracked state, never moving a withdrawal backwards
+func (t *WithdrawalTracker) update(observed TrackedWithdrawal) {
+	t.mu.Lock()
+
+	now := time.Now()
+	w, ok := t.withdrawals[observed.ID]
+	if !ok {
+		w = &TrackedWithdrawal{ID: observed.ID, Phase: WithdrawalCreated, CreatedAt: now}
+		t.withdrawals[observed.ID] = w
+	}
+	previous := *w
+
+	phase := withdrawalPhase(observed.Status, observed.SendTXID)
+	if !w.Phase.Terminal() && withdrawalPhaseRank[phase] >= withdrawalPhaseRank[w.Phase] {
+		w.Phase = phase
+		w.Status = observed.Status
+		w.StatusText = observed.StatusText
+	}
+
+	if w.SendTXID == "" {
+		w.SendTXID = observed.SendTXID
+	}
+	if observed.Currency != "" {
+		w.Currency = observed.Currency
+	}
+	if observed.Amount != "" {
+		w.Amount = observed.Amount
+	}
+	if observed.Address != "" {
+		w.Address = observed.Address
+	}
+	if !observed.CreatedAt.IsZero() && observed.CreatedAt.Unix() > 0 {
+		w.CreatedAt = observed.CreatedAt
+	}
+
+	changed := w.Phase != previous.Phase || w.SendTXID != previous.SendTXID
+	if changed {
+		w.Source = observed.Source
+		w.UpdatedAt = now
+	}
+	current := *w
+	t.mu.Unlock()
+
+	if changed {
+		for _, fn := range t.callbacks {
+			fn(previous, current)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package coinpayments
+
+import (
+	"testing"
+)
+
+func TestWithdrawalPhase(t *testing.T) {
+	tests := []struct {
+		status   int
+		sendTXID string
+		want     WithdrawalPhase
+	}{
+		{-1, "", WithdrawalCancelled},
+		{0, "", WithdrawalEmailConfirm},
+		{1, "", WithdrawalPending},
+		{1, "0xabc", WithdrawalSent},
+		{2, "0xabc", WithdrawalComplete},
+	}
+
+	for _, tt := range tests {
+		if got := withdrawalPhase(tt.status, tt.sendTXID); got != tt.want {
+			t.Errorf("withdrawalPhase(%v, %q) = %v, want %v", tt.status, tt.sendTXID, got, tt.want)
+		}
+	}
+}
+
+func TestWithdrawalTrackerNeverMovesBackwards(t *testing.T) {
+	var changes [][2]WithdrawalPhase
+	tracker := NewWithdrawalTracker(nil, WithWithdrawalChangeFunc(func(previous, current TrackedWithdrawal) {
+		changes = append(changes, [2]WithdrawalPhase{previous.Phase, current.Phase})
+	}))
+	tracker.Track("W1")
+
+	ipns := []*IPN{
+		newTestIPN(t, "ipn_type", "withdrawal", "id", "W1", "status", "1", "txn_id", "0xabc", "currency", "BTC", "amount", "1"),
+		//a late IPN from before the withdrawal was sent is ignored
+		newTestIPN(t, "ipn_type", "withdrawal", "id", "W1", "status", "0"),
+		newTestIPN(t, "ipn_type", "withdrawal", "id", "W1", "status", "2", "txn_id", "0xabc"),
+		//a finished withdrawal does not change again
+		newTestIPN(t, "ipn_type", "withdrawal", "id", "W1", "status", "-1"),
+	}
+	for _, ipn := range ipns {
+		if err := tracker.HandleIPN(ipn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, ok := tracker.Get("W1")
+	if !ok || w.Phase != WithdrawalComplete || w.SendTXID != "0xabc" || w.Currency != "BTC" || w.Amount != "1" {
+		t.Errorf("Get returned %+v, want a complete BTC withdrawal of 1 sent as 0xabc", w)
+	}
+	want := [][2]WithdrawalPhase{{WithdrawalCreated, WithdrawalSent}, {WithdrawalSent, WithdrawalComplete}}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("phase changes were %v, want %v", changes, want)
+	}
+}
+
+func TestWithdrawalTrackerPoll(t *testing.T) {
+	api := newFakeAPI()
+	api.set("get_withdrawal_info", `{"time_created":1600000000,"status":1,"status_text":"pending","coin":"LTC","amountf":"2","send_address":"addr"}`)
+	tracker := NewWithdrawalTracker(api.client())
+	tracker.Track("W1")
+
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := tracker.Get("W1"); w.Phase != WithdrawalPending || w.Source != "poll" || w.Address != "addr" || w.CreatedAt.Unix() != 1600000000 {
+		t.Fatalf("after polling the withdrawal is %+v, want pending to addr from the poll", w)
+	}
+
+	api.set("get_withdrawal_info", `{"status":2,"coin":"LTC","amountf":"2","send_txid":"0xdef"}`)
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n := api.count("get_withdrawal_info"); n != 2 {
+		t.Errorf("get_withdrawal_info was called %v times, want 2 since complete withdrawals are not polled", n)
+	}
+	if list := tracker.List(WithdrawalComplete); len(list) != 1 || list[0].SendTXID != "0xdef" {
+		t.Errorf("List of complete withdrawals returned %+v, want W1 sent as 0xdef", list)
+	}
+	if list := tracker.List(WithdrawalPending, WithdrawalSent); len(list) != 0 {
+		t.Errorf("List of unfinished withdrawals returned %+v, want none", list)
+	}
+}
@@ -0,0 +1,68 @@
+package coinpayments
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+//ErrorFunc receives the errors a background worker's Run loop handles without stopping
+type ErrorFunc func(err error)
+
+//WorkerErrors are the per item errors of a single pass over many items, the items that succeeded are not undone
+type WorkerErrors []error
+
+//Error joins the item errors
+func (e WorkerErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//err returns nil if no item failed, and the errors otherwise
+func (e WorkerErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+//appendErrors appends err to errs, flattening WorkerErrors
+func appendErrors(errs WorkerErrors, err error) WorkerErrors {
+	if list, ok := err.(WorkerErrors); ok {
+		return append(errs, list...)
+	}
+	return append(errs, err)
+}
+
+//runEvery calls fn now and on every interval until the context is done, reporting its errors instead of stopping
+func (c *Client) runEvery(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error, onError ErrorFunc) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			c.reportError(ctx, err, onError)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+//reportError passes each item error to onError, or logs it to the client's logger if onError is nil
+func (c *Client) reportError(ctx context.Context, err error, onError ErrorFunc) {
+	for _, err := range appendErrors(nil, err) {
+		switch {
+		case onError != nil:
+			onError(err)
+		case c.logger != nil:
+			c.logger.logger.ErrorContext(ctx, "coinpayments: background error", "error", err.Error())
+		}
+	}
+}